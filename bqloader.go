@@ -2,17 +2,21 @@ package bqloader
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"sync"
 	"time"
 
+	"cloud.google.com/go/bigquery"
 	"cloud.google.com/go/functions/metadata"
 	"github.com/rs/zerolog"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/xerrors"
 )
 
+var errStorageWriteDisposition = errors.New("WriteDisposition and Partitioner are supported only with LoadJob")
+
 // BQLoader loads data from Cloud Storage to BigQuery table.
 type BQLoader interface {
 	AddHandler(context.Context, *Handler) error
@@ -58,12 +62,19 @@ type bqloader struct {
 	logLevel      zerolog.Level
 	concurrency   int
 	semaphore     chan struct{}
+	ledger        Ledger
 }
 
 func (l *bqloader) AddHandler(ctx context.Context, h *Handler) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if h.RetryPolicy != nil && h.LoadMethod == StorageWriteCommitted {
+		err := xerrors.Errorf("invalid handler: %w", errCommittedRetry)
+		h.logger(ctx, l.logger).Err(err).Msg(err.Error())
+		return err
+	}
+
 	if h.Extractor == nil {
 		ex, err := newDefaultExtractor(ctx, h.Project)
 		if err != nil {
@@ -74,8 +85,14 @@ func (l *bqloader) AddHandler(ctx context.Context, h *Handler) error {
 		h.Extractor = ex
 	}
 
-	if h.Loader == nil {
-		loader, err := newDefaultLoader(ctx, h.Project, h.Dataset, h.Table)
+	if h.Router != nil {
+		if err := buildDestinations(ctx, h, newLoader); err != nil {
+			err = xerrors.Errorf("failed to build destinations: %w", err)
+			h.logger(ctx, l.logger).Err(err).Msg(err.Error())
+			return err
+		}
+	} else if h.Loader == nil {
+		loader, err := newLoader(ctx, h)
 		if err != nil {
 			err = xerrors.Errorf("failed to build default loader for table '%s.%s.%s': %w",
 				h.Project, h.Dataset, h.Table, err)
@@ -87,6 +104,10 @@ func (l *bqloader) AddHandler(ctx context.Context, h *Handler) error {
 
 	h.semaphore = l.semaphore
 
+	if h.Ledger == nil {
+		h.Ledger = l.ledger
+	}
+
 	if h.BatchSize == 0 {
 		h.BatchSize = defaultBatchSize
 	}
@@ -96,6 +117,13 @@ func (l *bqloader) AddHandler(ctx context.Context, h *Handler) error {
 	return nil
 }
 
+func (l *bqloader) ready() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return len(l.handlers) > 0
+}
+
 func (l *bqloader) MustAddHandler(ctx context.Context, h *Handler) {
 	if err := l.AddHandler(ctx, h); err != nil {
 		panic(err)
@@ -156,6 +184,16 @@ func contextualLogger(ctx context.Context, e Event, l *zerolog.Logger) *zerolog.
 		lctx = lctx.Time("started", t)
 	}
 
+	if ce, ok := cloudEventFrom(ctx); ok {
+		rl := lctx.Dict("metadata", cloudEventMetadata(ce)).Logger()
+		return &rl
+	}
+
+	if p, ok := pushRequestFrom(ctx); ok {
+		rl := lctx.Dict("metadata", pushRequestMetadata(p)).Logger()
+		return &rl
+	}
+
 	md, err := metadata.FromContext(ctx)
 	if err == nil {
 		rd := zerolog.Dict().
@@ -178,3 +216,20 @@ func contextualLogger(ctx context.Context, e Event, l *zerolog.Logger) *zerolog.
 	rl := lctx.Logger()
 	return &rl
 }
+
+// newLoader builds the default loader of the handler according to LoadMethod.
+func newLoader(ctx context.Context, h *Handler) (Loader, error) {
+	switch {
+	case h.LoadMethod == LoadJob:
+		return newDefaultLoader(ctx, h)
+	case h.Partitioner != nil || (h.WriteDisposition != "" && h.WriteDisposition != bigquery.WriteAppend):
+		return nil, errStorageWriteDisposition
+	}
+
+	l, err := newStorageWriteLoader(ctx, h.Project, h.Dataset, h.Table, h.LoadMethod, newTableSpec(h))
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
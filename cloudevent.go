@@ -0,0 +1,105 @@
+package bqloader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/rs/zerolog"
+	"golang.org/x/xerrors"
+)
+
+// StorageObjectFinalizedType is the CloudEvents type of events when objects are created in Cloud Storage.
+const StorageObjectFinalizedType = "google.cloud.storage.object.v1.finalized"
+
+var errUnsupportedEventType = errors.New("unsupported CloudEvents type")
+
+// EventFromCloudEvent converts a CloudEvent of Cloud Storage into Event.
+func EventFromCloudEvent(ce cloudevents.Event) (Event, error) {
+	if ce.Type() != StorageObjectFinalizedType {
+		return Event{}, xerrors.Errorf("%w: %s", errUnsupportedEventType, ce.Type())
+	}
+
+	var e Event
+	if err := ce.DataAs(&e); err != nil {
+		return Event{}, xerrors.Errorf("failed to decode CloudEvent data: %w", err)
+	}
+
+	return e, nil
+}
+
+// CloudEventFunction builds a function to handle CloudEvents of Cloud Storage with l,
+// such as the entrypoint of Cloud Functions 2nd gen with Functions Framework.
+// Events of types other than StorageObjectFinalizedType are ignored without errors not to be redelivered.
+func CloudEventFunction(l BQLoader) func(context.Context, cloudevents.Event) error {
+	return func(ctx context.Context, ce cloudevents.Event) error {
+		e, err := EventFromCloudEvent(ce)
+		if errors.Is(err, errUnsupportedEventType) {
+			return nil
+		}
+		if err != nil {
+			err = xerrors.Errorf("failed to convert CloudEvent %s: %w", ce.ID(), err)
+			if bq, ok := l.(*bqloader); ok {
+				bq.logger.Err(err).Msg(err.Error())
+			}
+			return err
+		}
+
+		return l.Handle(withCloudEvent(ctx, ce), e)
+	}
+}
+
+// NewCloudEventHandler builds an http.Handler which receives CloudEvents of Cloud Storage
+// in binary or structured content mode, such as events delivered by Eventarc.
+// It responds 204 if the event is loaded or its type is not supported, 400 if the request is not a valid event
+// and 500 if loading fails so that the event is redelivered.
+func NewCloudEventHandler(l BQLoader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveCloudEvent(w, r, l, cehttp.NewMessageFromHttpRequest(r))
+	})
+}
+
+func serveCloudEvent(w http.ResponseWriter, r *http.Request, l BQLoader, m *cehttp.Message) {
+	defer m.Finish(nil)
+
+	ce, err := binding.ToEvent(r.Context(), m)
+	if err != nil {
+		http.Error(w, "failed to read CloudEvent: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := ce.Validate(); err != nil {
+		http.Error(w, "invalid CloudEvent: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	e, err := EventFromCloudEvent(*ce)
+	// Acknowledge events not to be loaded as Pub/Sub notifications in NewHTTPHandler.
+	if errors.Is(err, errUnsupportedEventType) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := l.Handle(withCloudEvent(r.Context(), *ce), e); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func cloudEventMetadata(ce cloudevents.Event) *zerolog.Event {
+	return zerolog.Dict().
+		Str("eventId", ce.ID()).
+		Time("timestamp", ce.Time()).
+		Str("eventType", ce.Type()).
+		Str("source", ce.Source()).
+		Str("subject", ce.Subject())
+}
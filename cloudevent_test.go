@@ -0,0 +1,140 @@
+package bqloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+const cloudEventData = `{"bucket": "bucket", "name": "test/a.csv", "generation": "1", "contentType": "text/csv"}`
+
+func newCloudEventLoader(t *testing.T, l Loader) BQLoader {
+	t.Helper()
+
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "bucket", "test"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "bucket", "test", "a.csv"), []byte("a,b\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	loader, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loader.MustAddHandler(context.Background(), &Handler{
+		Name:      "test-handler",
+		Pattern:   regexp.MustCompile("^test/"),
+		Parser:    CSVParser(),
+		Projector: func(_ context.Context, r []string) ([]string, error) { return r, nil },
+		Extractor: NewFileExtractor(root),
+		Loader:    l,
+	})
+
+	return loader
+}
+
+func TestCloudEventFunction(t *testing.T) {
+	t.Parallel()
+
+	ce := cloudevents.NewEvent()
+	ce.SetID("1")
+	ce.SetSource("//storage.googleapis.com/projects/_/buckets/bucket")
+	ce.SetType(StorageObjectFinalizedType)
+	ce.SetSubject("objects/test/a.csv")
+	if err := ce.SetData(cloudevents.ApplicationJSON, []byte(cloudEventData)); err != nil {
+		t.Fatal(err)
+	}
+
+	tl := &testLoader{}
+	if err := CloudEventFunction(newCloudEventLoader(t, tl))(context.Background(), ce); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(tl.result) != 1 {
+		t.Errorf("expected 1 row, but %v", tl.result)
+	}
+
+	deleted := ce.Clone()
+	deleted.SetType("google.cloud.storage.object.v1.deleted")
+	tl = &testLoader{}
+	if err := CloudEventFunction(newCloudEventLoader(t, tl))(context.Background(), deleted); err != nil {
+		t.Errorf("unsupported type should be ignored, but %v", err)
+	}
+	if tl.result != nil {
+		t.Errorf("unsupported type should not be loaded, but %v", tl.result)
+	}
+
+	invalid := ce.Clone()
+	if err := invalid.SetData(cloudevents.ApplicationJSON, []byte("{")); err != nil {
+		t.Fatal(err)
+	}
+	if err := CloudEventFunction(newCloudEventLoader(t, &testLoader{}))(context.Background(), invalid); err == nil {
+		t.Errorf("expected error but no error occurred")
+	}
+}
+
+func TestNewCloudEventHandler(t *testing.T) {
+	t.Parallel()
+
+	binary := map[string]string{
+		"Content-Type":   "application/json",
+		"Ce-Specversion": "1.0",
+		"Ce-Id":          "1",
+		"Ce-Source":      "//storage.googleapis.com/projects/_/buckets/bucket",
+		"Ce-Type":        StorageObjectFinalizedType,
+		"Ce-Subject":     "objects/test/a.csv",
+	}
+
+	structured := `{"specversion": "1.0", "id": "1", "source": "//storage.googleapis.com/projects/_/buckets/bucket",
+		"type": "` + StorageObjectFinalizedType + `", "datacontenttype": "application/json", "data": ` + cloudEventData + `}`
+
+	cases := map[string]struct {
+		headers map[string]string
+		body    string
+		loader  Loader
+		status  int
+		rows    int
+	}{
+		"binary":           {binary, cloudEventData, &testLoader{}, http.StatusNoContent, 1},
+		"structured":       {map[string]string{"Content-Type": "application/cloudevents+json"}, structured, &testLoader{}, http.StatusNoContent, 1},
+		"not a CloudEvent": {map[string]string{"Content-Type": "application/json"}, cloudEventData, &testLoader{}, http.StatusBadRequest, 0},
+		"unsupported type": {
+			map[string]string{"Content-Type": "application/cloudevents+json"},
+			strings.Replace(structured, "finalized", "deleted", 1), &testLoader{}, http.StatusNoContent, 0,
+		},
+		"failed to load": {binary, cloudEventData, failingLoader{}, http.StatusInternalServerError, 0},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(c.body))
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+
+			NewCloudEventHandler(newCloudEventLoader(t, c.loader)).ServeHTTP(rec, req)
+
+			if rec.Code != c.status {
+				t.Errorf("expected status %d, but %d: %s", c.status, rec.Code, rec.Body.String())
+			}
+
+			if tl, ok := c.loader.(*testLoader); ok && len(tl.result) != c.rows {
+				t.Errorf("expected %d rows, but %v", c.rows, tl.result)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+/*
+Command bqloader runs bqloader handlers locally against local files or Cloud Storage objects.
+It's useful to test handlers and to backfill past files.
+
+Usage:
+
+	bqloader [flags] FILE...
+
+FILE is a local path or a Cloud Storage URL beginning with gs://. Both accept glob patterns
+like 'statements/2022-*.csv' or 'gs://bucket/statements/2022-*.csv'.
+
+Handlers are given by either a configuration file of go.nownabe.dev/bqloader/config
+or a name of a pre-configured handler in go.nownabe.dev/bqloader/contrib/handlers.
+With a configuration file, each file is processed by handlers whose patterns match the absolute
+file path with forward slashes (or the object name for Cloud Storage).
+
+	# Print projected rows as a table.
+	bqloader -handler SMBCCardStatement -format table 202207.csv
+
+	# Check monthly statements with handlers in a configuration file without loading.
+	bqloader -config bqloader.yaml -dry-run 'gs://my-bucket/amex/2022-*.xls'
+
+	# Load them into BigQuery.
+	bqloader -config bqloader.yaml -load 'gs://my-bucket/amex/2022-*.xls'
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"go.nownabe.dev/bqloader"
+	"go.nownabe.dev/bqloader/config"
+	"go.nownabe.dev/bqloader/contrib/handlers"
+	"golang.org/x/xerrors"
+)
+
+var (
+	errNoHandler = errors.New("either -config or -handler is required")
+	errNoFile    = errors.New("no files are given")
+)
+
+type options struct {
+	config      string
+	handler     string
+	only        string
+	pattern     string
+	project     string
+	dataset     string
+	table       string
+	format      string
+	output      string
+	load        bool
+	dryRun      bool
+	notify      bool
+	concurrency int
+	logLevel    string
+}
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	opts, files, err := parseFlags(args, stderr)
+	if err != nil {
+		return err
+	}
+
+	hs, err := buildHandlers(opts)
+	if err != nil {
+		return err
+	}
+
+	srcs := &sources{}
+	defer srcs.close()
+
+	events, err := srcs.expand(ctx, files)
+	if err != nil {
+		return err
+	}
+
+	if len(events) == 0 {
+		return errNoFile
+	}
+
+	out := stdout
+	if opts.output != "" && !opts.dryRun {
+		f, err := os.Create(opts.output)
+		if err != nil {
+			return xerrors.Errorf("failed to create %s: %w", opts.output, err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	bq, err := bqloader.New(bqloader.WithConcurrency(opts.concurrency))
+	if err != nil {
+		return xerrors.Errorf("failed to build bqloader: %w", err)
+	}
+
+	var w *recordWriter
+	if !opts.load && !opts.dryRun {
+		w, err = newRecordWriter(out, opts.format)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, h := range hs {
+		h.Extractor = srcs
+		if !opts.notify {
+			h.Notifier = nil
+		}
+		if !opts.load || opts.dryRun {
+			h.Loader = &writerLoader{w: w}
+		}
+
+		if err := bq.AddHandler(ctx, h); err != nil {
+			return xerrors.Errorf("failed to add handler %s: %w", h.Name, err)
+		}
+	}
+
+	level, err := zerolog.ParseLevel(opts.logLevel)
+	if err != nil {
+		return xerrors.Errorf("invalid log level: %w", err)
+	}
+	logger := zerolog.New(zerolog.ConsoleWriter{Out: stderr}).Level(level).With().Timestamp().Logger()
+	ctx = logger.WithContext(ctx)
+
+	failed := 0
+
+	for _, e := range events {
+		matched := matchHandlers(hs, e, opts)
+		if len(matched) == 0 {
+			fmt.Fprintf(stderr, "%s: no handler matched\n", e.displayName())
+			continue
+		}
+
+		fileFailed := false
+
+		for _, h := range matched {
+			res, err := handle(ctx, h, e.Event)
+			if err != nil {
+				fileFailed = true
+				fmt.Fprintf(stderr, "%s: %s: %v\n", e.displayName(), h.Name, err)
+				continue
+			}
+
+			verb := "projected"
+			if opts.load && !opts.dryRun {
+				verb = "loaded"
+			}
+
+			fmt.Fprintf(stderr, "%s: %s: %d rows parsed, %d skipped, %d rejected, %d %s\n",
+				e.displayName(), h.Name, res.ParsedRows, res.SkippedRows, len(res.RejectedRows), res.LoadedRows, verb)
+		}
+
+		if fileFailed {
+			failed++
+		}
+	}
+
+	if w != nil {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return xerrors.Errorf("%d of %d files failed", failed, len(events))
+	}
+
+	return nil
+}
+
+func parseFlags(args []string, stderr io.Writer) (*options, []string, error) {
+	opts := &options{}
+
+	fs := flag.NewFlagSet("bqloader", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: bqloader [flags] FILE...\n\nFlags:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(stderr, "\nPre-configured handlers:\n  %s\n", strings.Join(handlers.Names(), "\n  "))
+	}
+
+	fs.StringVar(&opts.config, "config", "", "path to a configuration file in YAML or JSON")
+	fs.StringVar(&opts.handler, "handler", "", "name of a pre-configured handler such as SMBCStatement")
+	fs.StringVar(&opts.only, "only", "", "run only the handler with this name in the configuration ignoring patterns")
+	fs.StringVar(&opts.pattern, "pattern", "", "pattern for -handler (default matches all files)")
+	fs.StringVar(&opts.project, "project", "", "destination BigQuery project for -handler")
+	fs.StringVar(&opts.dataset, "dataset", "", "destination BigQuery dataset for -handler")
+	fs.StringVar(&opts.table, "table", "", "destination BigQuery table for -handler")
+	fs.StringVar(&opts.format, "format", "csv", "output format of projected rows: csv, json or table")
+	fs.StringVar(&opts.output, "output", "", "write projected rows into this file instead of stdout")
+	fs.BoolVar(&opts.load, "load", false, "load projected rows into BigQuery instead of printing them")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "parse and project files but neither print nor load rows")
+	fs.BoolVar(&opts.notify, "notify", false, "send notifications configured in handlers")
+	fs.IntVar(&opts.concurrency, "concurrency", 1, "concurrency of projectors")
+	fs.StringVar(&opts.logLevel, "log-level", "error", "log level: trace, debug, info, warn or error")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if (opts.config == "") == (opts.handler == "") {
+		fs.Usage()
+		return nil, nil, errNoHandler
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return nil, nil, errNoFile
+	}
+
+	return opts, fs.Args(), nil
+}
+
+func buildHandlers(opts *options) ([]*bqloader.Handler, error) {
+	if opts.config != "" {
+		hs, err := config.LoadHandlers(opts.config)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load handlers: %w", err)
+		}
+		return hs, nil
+	}
+
+	c, ok := handlers.Lookup(opts.handler)
+	if !ok {
+		return nil, xerrors.Errorf("unknown handler: %s", opts.handler)
+	}
+
+	pattern := opts.pattern
+	if pattern == "" {
+		pattern = ".*"
+	}
+
+	t := handlers.Table{Project: opts.project, Dataset: opts.dataset, Table: opts.table}
+
+	return []*bqloader.Handler{c(opts.handler, pattern, t, nil)}, nil
+}
+
+func matchHandlers(hs []*bqloader.Handler, e *event, opts *options) []*bqloader.Handler {
+	matched := []*bqloader.Handler{}
+
+	for _, h := range hs {
+		if opts.only != "" {
+			if h.Name == opts.only {
+				matched = append(matched, h)
+			}
+			continue
+		}
+
+		if h.Pattern != nil && h.Pattern.MatchString(e.Name) {
+			matched = append(matched, h)
+		}
+	}
+
+	return matched
+}
+
+// handle handles an event by the handler and returns its result.
+func handle(ctx context.Context, h *bqloader.Handler, e bqloader.Event) (*bqloader.Result, error) {
+	notifier := &resultNotifier{next: h.Notifier}
+	h.Notifier = notifier
+	defer func() { h.Notifier = notifier.next }()
+
+	err := h.Handle(ctx, e)
+
+	return notifier.result, err
+}
+
+// resultNotifier captures the result of a handler and passes it to the original notifier.
+type resultNotifier struct {
+	next   bqloader.Notifier
+	result *bqloader.Result
+}
+
+func (n *resultNotifier) Notify(ctx context.Context, r *bqloader.Result) error {
+	n.result = r
+
+	if n.next == nil {
+		return nil
+	}
+
+	return n.next.Notify(ctx, r)
+}
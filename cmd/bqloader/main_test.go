@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, body string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_run_config(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "bank", "2022-07.csv"), "date,amount\n2022/07/01,\"1,000\"\n")
+	writeFile(t, filepath.Join(dir, "bank", "2022-08.csv"), "date,amount\n2022/08/01,2000\n")
+	writeFile(t, filepath.Join(dir, "other", "2022-08.csv"), "date,amount\n2022/08/01,3000\n")
+
+	configPath := filepath.Join(dir, "bqloader.yaml")
+	writeFile(t, configPath, `
+handlers:
+  - name: bank
+    pattern: /bank/
+    skipLeadingRows: 1
+    columns:
+      - column: 0
+        transforms: [{type: date, from: 2006/01/02}]
+      - column: 1
+        transforms: [{type: clean_number}]
+    destination: {project: p, dataset: d, table: t}
+`)
+
+	cases := map[string]struct {
+		args     []string
+		expected string
+	}{
+		"csv": {
+			args:     []string{"-config", configPath, filepath.Join(dir, "*", "2022-*.csv")},
+			expected: "2022-07-01,1000\n2022-08-01,2000\n",
+		},
+		"json": {
+			args:     []string{"-config", configPath, "-format", "json", filepath.Join(dir, "bank", "2022-07.csv")},
+			expected: "[\"2022-07-01\",\"1000\"]\n",
+		},
+		"table": {
+			args:     []string{"-config", configPath, "-format", "table", filepath.Join(dir, "bank", "*.csv")},
+			expected: "2022-07-01  1000\n2022-08-01  2000\n",
+		},
+		"dry-run": {
+			args:     []string{"-config", configPath, "-dry-run", filepath.Join(dir, "bank", "*.csv")},
+			expected: "",
+		},
+		"only": {
+			args:     []string{"-config", configPath, "-only", "bank", filepath.Join(dir, "other", "*.csv")},
+			expected: "2022-08-01,3000\n",
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			if err := run(context.Background(), c.args, stdout, stderr); err != nil {
+				t.Fatalf("Unexpected error: %v\n%s", err, stderr)
+			}
+
+			if stdout.String() != c.expected {
+				t.Errorf("expected output %q, but %q", c.expected, stdout)
+			}
+		})
+	}
+}
+
+func Test_run_handler(t *testing.T) {
+	t.Parallel()
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	output := filepath.Join(t.TempDir(), "out.csv")
+
+	args := []string{"-handler", "SMBCStatement", "-output", output, "../../contrib/handlers/testdata/smbc_statement.csv"}
+	if err := run(context.Background(), args, stdout, stderr); err != nil {
+		t.Fatalf("Unexpected error: %v\n%s", err, stderr)
+	}
+
+	body, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "2019-12-04,10389,,") {
+		t.Errorf("unexpected output: %s", body)
+	}
+
+	if !strings.Contains(stderr.String(), "3 rows parsed") {
+		t.Errorf("unexpected summary: %s", stderr)
+	}
+}
+
+// Test_run_bare_file_name changes the working directory, so it doesn't run in parallel.
+func Test_run_bare_file_name(t *testing.T) {
+	body, err := os.ReadFile("../../contrib/handlers/testdata/smbc_card_statement.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "202012.csv"), string(body))
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	args := []string{"-handler", "SMBCCardStatement", "-format", "table", "202012.csv"}
+	if err := run(context.Background(), args, stdout, stderr); err != nil {
+		t.Fatalf("Unexpected error: %v\n%s", err, stderr)
+	}
+
+	if stdout.Len() == 0 {
+		t.Errorf("no rows are printed")
+	}
+
+	if !strings.HasPrefix(stderr.String(), "202012.csv: SMBCCardStatement: ") {
+		t.Errorf("unexpected summary: %s", stderr)
+	}
+}
+
+func Test_run_failed_file(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "good.csv"), "2022/07/01,1000\n")
+	writeFile(t, filepath.Join(dir, "bad.csv"), strings.Repeat("2022/07/02,2000\n", 1000)+"invalid,3000\n")
+
+	configPath := filepath.Join(dir, "bqloader.yaml")
+	writeFile(t, configPath, `
+handlers:
+  - name: bank
+    pattern: \.csv$
+    batchSize: 1
+    columns:
+      - column: 0
+        transforms: [{type: date, from: 2006/01/02}]
+      - column: 1
+    destination: {project: p, dataset: d, table: t}
+  - name: bank2
+    pattern: \.csv$
+    columns:
+      - column: 0
+        transforms: [{type: date, from: 2006/01/02}]
+      - column: 1
+    destination: {project: p, dataset: d, table: t2}
+`)
+
+	stdout := &bytes.Buffer{}
+	args := []string{"-config", configPath, filepath.Join(dir, "*.csv")}
+
+	// bad.csv failed in both handlers is counted once.
+	err := run(context.Background(), args, stdout, &bytes.Buffer{})
+	if err == nil || err.Error() != "1 of 2 files failed" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Records of the failed file must not be written.
+	if expected := "2022-07-01,1000\n2022-07-01,1000\n"; stdout.String() != expected {
+		t.Errorf("expected output %q, but %q", expected, stdout)
+	}
+}
+
+func Test_run_error(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string][]string{
+		"no handler":      {"file.csv"},
+		"no file":         {"-handler", "SMBCStatement"},
+		"unknown handler": {"-handler", "Unknown", "file.csv"},
+		"not found":       {"-handler", "SMBCStatement", filepath.Join(t.TempDir(), "*.csv")},
+		"unknown format":  {"-handler", "SMBCStatement", "-format", "xml", "../../contrib/handlers/testdata/smbc_statement.csv"},
+		"failed":          {"-handler", "SonyBankStatement", "../../contrib/handlers/testdata/smbc_statement.csv"},
+	}
+
+	for name, args := range cases {
+		args := args
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := run(context.Background(), args, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+				t.Errorf("expected error but no error occurred")
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"golang.org/x/xerrors"
+)
+
+// recordWriter writes projected records in a format.
+type recordWriter struct {
+	mu    sync.Mutex
+	write func([]string) error
+	flush func() error
+}
+
+func newRecordWriter(w io.Writer, format string) (*recordWriter, error) {
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		return &recordWriter{
+			write: cw.Write,
+			flush: func() error {
+				cw.Flush()
+				return cw.Error()
+			},
+		}, nil
+	case "json":
+		enc := json.NewEncoder(w)
+		return &recordWriter{
+			write: func(r []string) error { return enc.Encode(r) },
+			flush: func() error { return nil },
+		}, nil
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		return &recordWriter{
+			write: func(r []string) error {
+				_, err := io.WriteString(tw, strings.Join(r, "\t")+"\n")
+				return err
+			},
+			flush: tw.Flush,
+		}, nil
+	default:
+		return nil, xerrors.Errorf("unknown format: %s", format)
+	}
+}
+
+func (w *recordWriter) writeRecords(rs [][]string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, r := range rs {
+		if err := w.write(r); err != nil {
+			return xerrors.Errorf("failed to write a record: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// writerLoader is a bqloader.Loader writing records into recordWriter.
+// It doesn't implement bqloader.StreamLoader so that records of a file are written
+// only after the file is processed successfully. If w is nil, records are discarded.
+type writerLoader struct {
+	w *recordWriter
+}
+
+func (l *writerLoader) Load(_ context.Context, records [][]string) error {
+	if l.w == nil {
+		return nil
+	}
+
+	return l.w.writeRecords(records)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"go.nownabe.dev/bqloader"
+	"golang.org/x/xerrors"
+	"google.golang.org/api/iterator"
+)
+
+// event is an event for a local file or a Cloud Storage object.
+type event struct {
+	bqloader.Event
+	local bool
+
+	// path is the path of a local file as specified.
+	path string
+}
+
+func (e *event) displayName() string {
+	if e.local {
+		return e.path
+	}
+
+	return e.FullPath()
+}
+
+// sources expands file arguments into events and extracts them.
+// Local files are represented as events with empty bucket and their absolute paths,
+// so that patterns of handlers expecting directories such as `/(\d+)\.csv` match bare file names.
+type sources struct {
+	mu      sync.Mutex
+	storage *storage.Client
+}
+
+func (s *sources) close() {
+	if s.storage != nil {
+		s.storage.Close()
+	}
+}
+
+func (s *sources) client(ctx context.Context) (*storage.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.storage == nil {
+		c, err := storage.NewClient(ctx)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to build storage client: %w", err)
+		}
+		s.storage = c
+	}
+
+	return s.storage, nil
+}
+
+// expand expands glob patterns in files.
+func (s *sources) expand(ctx context.Context, files []string) ([]*event, error) {
+	events := []*event{}
+
+	for _, f := range files {
+		var es []*event
+		var err error
+
+		if strings.HasPrefix(f, "gs://") {
+			es, err = s.expandGCS(ctx, f)
+		} else {
+			es, err = expandLocal(f)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, es...)
+	}
+
+	return events, nil
+}
+
+func expandLocal(pattern string) ([]*event, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid pattern %s: %w", pattern, err)
+	}
+
+	if len(paths) == 0 {
+		return nil, xerrors.Errorf("no such file: %s", pattern)
+	}
+
+	events := make([]*event, 0, len(paths))
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to get absolute path of %s: %w", p, err)
+		}
+
+		events = append(events, &event{Event: bqloader.Event{Name: filepath.ToSlash(abs)}, local: true, path: p})
+	}
+
+	return events, nil
+}
+
+func (s *sources) expandGCS(ctx context.Context, url string) ([]*event, error) {
+	bucket, pattern, ok := strings.Cut(strings.TrimPrefix(url, "gs://"), "/")
+	if !ok || bucket == "" || pattern == "" {
+		return nil, xerrors.Errorf("invalid Cloud Storage URL: %s", url)
+	}
+
+	// Without wildcards, the object is used as is.
+	wildcard := strings.IndexAny(pattern, "*?[\\")
+	if wildcard < 0 {
+		return []*event{{Event: bqloader.Event{Bucket: bucket, Name: pattern}}}, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, xerrors.Errorf("invalid pattern %s: %w", url, err)
+	}
+
+	c, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []*event{}
+	it := c.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: pattern[:wildcard]})
+
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("failed to list objects of %s: %w", url, err)
+		}
+
+		if ok, _ := path.Match(pattern, attrs.Name); ok {
+			events = append(events, &event{Event: bqloader.Event{
+				Bucket:         bucket,
+				Name:           attrs.Name,
+				TimeCreated:    attrs.Created,
+				Generation:     attrs.Generation,
+				Metageneration: attrs.Metageneration,
+				ContentType:    attrs.ContentType,
+				Size:           attrs.Size,
+				Metadata:       attrs.Metadata,
+				Updated:        attrs.Updated,
+			}})
+		}
+	}
+
+	if len(events) == 0 {
+		return nil, xerrors.Errorf("no such object: %s", url)
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Name < events[j].Name })
+
+	return events, nil
+}
+
+// Extract opens a local file if the bucket is empty, otherwise a Cloud Storage object.
+func (s *sources) Extract(ctx context.Context, e bqloader.Event) (io.Reader, func(), error) {
+	if e.Bucket == "" {
+		f, err := os.Open(filepath.FromSlash(e.Name))
+		if err != nil {
+			return nil, nil, xerrors.Errorf("failed to open %s: %w", e.Name, err)
+		}
+
+		return f, func() { f.Close() }, nil
+	}
+
+	c, err := s.client(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := c.Bucket(e.Bucket).Object(e.Name).NewReader(ctx)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to get reader of %s: %w", e.FullPath(), err)
+	}
+
+	return r, func() { r.Close() }, nil
+}
@@ -0,0 +1,278 @@
+package config
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.nownabe.dev/bqloader"
+	"go.nownabe.dev/bqloader/contrib/handlers"
+	"golang.org/x/xerrors"
+)
+
+// ColumnConfig defines a column of destination records.
+// Exactly one of Column, Name, Constant and ObjectPath is required.
+type ColumnConfig struct {
+	// Column is a 0-origin index of a source column.
+	Column *int `yaml:"column"`
+
+	// Name is a column name in the header. Name requires header_csv parser.
+	Name string `yaml:"name"`
+
+	// Constant is a constant value.
+	Constant *string `yaml:"constant"`
+
+	// ObjectPath is a regular expression matched to the object path.
+	// The first capturing group is used as the value.
+	ObjectPath string `yaml:"objectPath"`
+
+	// Transforms are applied to the value in order.
+	Transforms []*TransformConfig `yaml:"transforms"`
+}
+
+// TransformConfig defines a transform of a column value.
+type TransformConfig struct {
+	// Type is one of date, clean_number and trim.
+	//   date: reformats a date from From layout to To layout. To is "2006-01-02" by default.
+	//   clean_number: removes commas and currency marks with handlers.CleanNumber.
+	//   trim: removes leading and trailing white spaces.
+	Type string `yaml:"type"`
+
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+type contextKey string
+
+const objectPathKey contextKey = "objectPath"
+
+type valueFunc func(ctx context.Context, columns []string, row *bqloader.Row) (string, error)
+
+type transformFunc func(string) (string, error)
+
+type column struct {
+	value      valueFunc
+	transforms []transformFunc
+}
+
+func (c *column) get(ctx context.Context, columns []string, row *bqloader.Row) (string, error) {
+	v, err := c.value(ctx, columns, row)
+	if err != nil {
+		return "", err
+	}
+
+	for _, t := range c.transforms {
+		if v, err = t(v); err != nil {
+			return "", err
+		}
+	}
+
+	return v, nil
+}
+
+// applyProjector sets a projector and a preprocessor built from columns to the handler.
+func (c *HandlerConfig) applyProjector(h *bqloader.Handler) error {
+	named := c.Parser.named()
+	var pathREs []*regexp.Regexp
+
+	build := func(cc *ColumnConfig) (*column, error) {
+		col, re, err := cc.build(named, len(pathREs))
+		if err != nil {
+			return nil, err
+		}
+		if re != nil {
+			pathREs = append(pathREs, re)
+		}
+		return col, nil
+	}
+
+	columns := make([]*column, 0, len(c.Columns))
+	for i, cc := range c.Columns {
+		col, err := build(cc)
+		if err != nil {
+			return xerrors.Errorf("columns[%d]: %w", i, err)
+		}
+		columns = append(columns, col)
+	}
+
+	var skip *column
+	if c.SkipIfEmpty != nil {
+		col, err := build(c.SkipIfEmpty)
+		if err != nil {
+			return xerrors.Errorf("skipIfEmpty: %w", err)
+		}
+		skip = col
+	}
+
+	project := func(ctx context.Context, source []string, row *bqloader.Row) ([]string, error) {
+		if skip != nil {
+			v, err := skip.get(ctx, source, row)
+			if err != nil {
+				return nil, err
+			}
+			if v == "" {
+				return nil, nil
+			}
+		}
+
+		if len(columns) == 0 {
+			return source, nil
+		}
+
+		record := make([]string, len(columns))
+		for i, col := range columns {
+			v, err := col.get(ctx, source, row)
+			if err != nil {
+				return nil, xerrors.Errorf("column %d: %w", i, err)
+			}
+			record[i] = v
+		}
+
+		return record, nil
+	}
+
+	if named {
+		h.NamedProjector = func(ctx context.Context, r *bqloader.Row) ([]string, error) {
+			return project(ctx, r.Columns(), r)
+		}
+	} else {
+		h.Projector = func(ctx context.Context, r []string) ([]string, error) {
+			return project(ctx, r, nil)
+		}
+	}
+
+	if len(pathREs) > 0 {
+		h.Preprocessor = func(ctx context.Context, e bqloader.Event) (context.Context, error) {
+			values := make([]string, len(pathREs))
+
+			for i, re := range pathREs {
+				match := re.FindStringSubmatch(e.Name)
+				if len(match) < 2 {
+					return ctx, xerrors.Errorf("object path %s doesn't match %s", e.Name, re)
+				}
+				values[i] = match[1]
+			}
+
+			return context.WithValue(ctx, objectPathKey, values), nil
+		}
+	}
+
+	return nil
+}
+
+// build builds a column. If the column refers the object path, build also returns its regular expression
+// and the captured value is stored at pathIndex.
+func (c *ColumnConfig) build(named bool, pathIndex int) (*column, *regexp.Regexp, error) {
+	col := &column{}
+	var re *regexp.Regexp
+	sources := 0
+
+	if c.Column != nil {
+		sources++
+		i := *c.Column
+		if i < 0 {
+			return nil, nil, xerrors.Errorf("column must not be negative: %d", i)
+		}
+
+		col.value = func(_ context.Context, columns []string, _ *bqloader.Row) (string, error) {
+			if i >= len(columns) {
+				return "", xerrors.Errorf("column %d is out of range of %d columns", i, len(columns))
+			}
+			return columns[i], nil
+		}
+	}
+
+	if c.Name != "" {
+		sources++
+		if !named {
+			return nil, nil, xerrors.Errorf("name requires header_csv parser: %s", c.Name)
+		}
+
+		name := c.Name
+		col.value = func(_ context.Context, _ []string, row *bqloader.Row) (string, error) {
+			return row.Get(name), nil
+		}
+	}
+
+	if c.Constant != nil {
+		sources++
+		v := *c.Constant
+		col.value = func(context.Context, []string, *bqloader.Row) (string, error) {
+			return v, nil
+		}
+	}
+
+	if c.ObjectPath != "" {
+		sources++
+
+		var err error
+		re, err = regexp.Compile(c.ObjectPath)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("invalid objectPath: %w", err)
+		}
+		if re.NumSubexp() < 1 {
+			return nil, nil, xerrors.Errorf("objectPath must have a capturing group: %s", c.ObjectPath)
+		}
+
+		col.value = func(ctx context.Context, _ []string, _ *bqloader.Row) (string, error) {
+			values, ok := ctx.Value(objectPathKey).([]string)
+			if !ok {
+				return "", xerrors.New("failed to get object path values from context")
+			}
+			return values[pathIndex], nil
+		}
+	}
+
+	if sources != 1 {
+		return nil, nil, xerrors.New("exactly one of column, name, constant and objectPath is required")
+	}
+
+	for i, tc := range c.Transforms {
+		t, err := tc.build()
+		if err != nil {
+			return nil, nil, xerrors.Errorf("transforms[%d]: %w", i, err)
+		}
+		col.transforms = append(col.transforms, t)
+	}
+
+	return col, re, nil
+}
+
+func (c *TransformConfig) build() (transformFunc, error) {
+	switch c.Type {
+	case "date":
+		if c.From == "" {
+			return nil, xerrors.New("date transform requires from")
+		}
+
+		from := c.From
+		to := c.To
+		if to == "" {
+			to = "2006-01-02"
+		}
+
+		return func(v string) (string, error) {
+			if v == "" {
+				return "", nil
+			}
+
+			t, err := time.Parse(from, v)
+			if err != nil {
+				return "", xerrors.Errorf("failed to parse date: %w", err)
+			}
+
+			return t.Format(to), nil
+		}, nil
+	case "clean_number":
+		return func(v string) (string, error) {
+			return handlers.CleanNumber(v), nil
+		}, nil
+	case "trim":
+		return func(v string) (string, error) {
+			return strings.TrimSpace(v), nil
+		}, nil
+	default:
+		return nil, xerrors.Errorf("unknown transform type: %s", c.Type)
+	}
+}
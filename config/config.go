@@ -0,0 +1,262 @@
+/*
+Package config builds bqloader handlers from declarative configuration files in YAML or JSON.
+
+	handlers:
+	  # A custom handler.
+	  - name: example bank
+	    pattern: ^example_bank/
+	    encoding: shift_jis
+	    parser:
+	      type: csv
+	    skipLeadingRows: 1
+	    skipIfEmpty:
+	      column: 0
+	    columns:
+	      - column: 0
+	        transforms:
+	          - type: date
+	            from: 2006/01/02
+	            to: 2006-01-02
+	      - column: 1
+	      - column: 2
+	        transforms:
+	          - type: clean_number
+	      - objectPath: '(\d{4}-\d{2})\.csv$'
+	        transforms:
+	          - type: date
+	            from: 2006-01
+	            to: 2006-01-02
+	    destination:
+	      project: ${BIGQUERY_PROJECT_ID}
+	      dataset: ${BIGQUERY_DATASET_ID}
+	      table: example_bank
+	    retry:
+	      maxAttempts: 3
+	      initialBackoff: 1s
+	    notifier:
+	      type: slack
+	      channel: ${SLACK_CHANNEL}
+	      token: ${SLACK_TOKEN}
+
+	  # A pre-configured handler in go.nownabe.dev/bqloader/contrib/handlers.
+	  - name: SMBC
+	    contrib: SMBCStatement
+	    pattern: ^smbc/
+	    destination:
+	      project: ${BIGQUERY_PROJECT_ID}
+	      dataset: ${BIGQUERY_DATASET_ID}
+	      table: smbc
+
+Environment variables in the form of ${VAR} are expanded before parsing.
+Other dollar signs such as $ and $1 in regular expressions are kept as is.
+JSON files are accepted as well because JSON is a subset of YAML.
+*/
+package config
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"time"
+
+	"go.nownabe.dev/bqloader"
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a configuration of handlers.
+type Config struct {
+	Handlers []*HandlerConfig `yaml:"handlers"`
+}
+
+// HandlerConfig is a configuration of a handler.
+type HandlerConfig struct {
+	// Name is the handler's name.
+	Name string `yaml:"name"`
+
+	// Contrib is a name of a pre-configured handler in contrib/handlers such as "SMBCStatement".
+	// Only Name, Pattern, Destination, Retry and Notifier are used with Contrib.
+	Contrib string `yaml:"contrib"`
+
+	// Pattern is a regular expression matched to object paths.
+	Pattern string `yaml:"pattern"`
+
+	// Encoding is a name of the source encoding such as "shift_jis" or "euc-jp". Default is UTF-8.
+	Encoding string `yaml:"encoding"`
+
+	Parser          *ParserConfig `yaml:"parser"`
+	SkipLeadingRows uint          `yaml:"skipLeadingRows"`
+
+	// SkipIfEmpty skips source rows whose specified column is empty.
+	SkipIfEmpty *ColumnConfig `yaml:"skipIfEmpty"`
+
+	// Columns defines columns of destination records. Source records are loaded as is if empty.
+	Columns []*ColumnConfig `yaml:"columns"`
+
+	AppendLineNumber bool `yaml:"appendLineNumber"`
+	BatchSize        int  `yaml:"batchSize"`
+
+	// MaxBadRows and MaxBadRatio configure ErrorPolicy. Default is FailFast.
+	MaxBadRows  int     `yaml:"maxBadRows"`
+	MaxBadRatio float64 `yaml:"maxBadRatio"`
+
+	// Retry configures RetryPolicy. Default is no retries.
+	Retry *RetryConfig `yaml:"retry"`
+
+	Destination *DestinationConfig `yaml:"destination"`
+	Notifier    *NotifierConfig    `yaml:"notifier"`
+}
+
+// ParserConfig is a configuration of a parser.
+type ParserConfig struct {
+	// Type is one of csv, header_csv and partial_csv. csv and header_csv parse files row by row.
+	Type string `yaml:"type"`
+
+	// SkipHeadRows, SkipTailRows and Separator are options for partial_csv.
+	SkipHeadRows uint   `yaml:"skipHeadRows"`
+	SkipTailRows uint   `yaml:"skipTailRows"`
+	Separator    string `yaml:"separator"`
+}
+
+// DestinationConfig identifies a destination BigQuery table.
+type DestinationConfig struct {
+	Project string `yaml:"project"`
+	Dataset string `yaml:"dataset"`
+	Table   string `yaml:"table"`
+
+	// Method is one of load_job, storage_write_committed and storage_write_pending.
+	// Default is load_job.
+	Method string `yaml:"method"`
+
+	// WriteDisposition is one of append and truncate. Default is append.
+	WriteDisposition string `yaml:"writeDisposition"`
+
+	// Partition decides a partition to write from the object path. Optional.
+	Partition *PartitionConfig `yaml:"partition"`
+
+	// Schema declares the table schema to create the table if missing and to detect schema drift. Optional.
+	// TimePartitioning and Clustering are used to create the table.
+	Schema           []*FieldConfig          `yaml:"schema"`
+	TimePartitioning *TimePartitioningConfig `yaml:"timePartitioning"`
+	Clustering       []string                `yaml:"clustering"`
+}
+
+// FieldConfig is a column of a table schema in the same format as schema files of the bq command.
+type FieldConfig struct {
+	Name        string `yaml:"name" json:"name"`
+	Type        string `yaml:"type" json:"type"`
+	Mode        string `yaml:"mode" json:"mode,omitempty"`
+	Description string `yaml:"description" json:"description,omitempty"`
+}
+
+// TimePartitioningConfig is a configuration of time partitioning.
+type TimePartitioningConfig struct {
+	// Type is one of HOUR, DAY, MONTH and YEAR.
+	Type string `yaml:"type"`
+
+	// Field is the partitioning column. Ingestion time partitioning is used if empty.
+	Field string `yaml:"field"`
+}
+
+// PartitionConfig decides a partition from the object path.
+type PartitionConfig struct {
+	// ObjectPath is a regular expression matched to the object path.
+	// The first capturing group is parsed as time with From.
+	ObjectPath string `yaml:"objectPath"`
+	From       string `yaml:"from"`
+
+	// Type is one of hour, day, month and year.
+	Type string `yaml:"type"`
+}
+
+// RetryConfig is a configuration of a retry policy.
+// Zero values are defaults of bqloader.DefaultRetryPolicy.
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"maxAttempts"`
+	InitialBackoff time.Duration `yaml:"initialBackoff"`
+	MaxBackoff     time.Duration `yaml:"maxBackoff"`
+}
+
+// NotifierConfig is a configuration of a notifier.
+type NotifierConfig struct {
+	// Type is only slack for now.
+	Type string `yaml:"type"`
+
+	Channel   string `yaml:"channel"`
+	Token     string `yaml:"token"`
+	IconEmoji string `yaml:"iconEmoji"`
+	Username  string `yaml:"username"`
+}
+
+// Load reads a configuration file in YAML or JSON.
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read %s: %w", path, err)
+	}
+
+	c, err := Parse(b)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// Parse parses a configuration in YAML or JSON.
+func Parse(b []byte) (*Config, error) {
+	var c Config
+
+	if err := yaml.Unmarshal(expandEnv(b), &c); err != nil {
+		return nil, xerrors.Errorf("failed to unmarshal: %w", err)
+	}
+
+	return &c, nil
+}
+
+var envRE = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv expands only ${VAR} not to break regular expressions like `\.csv$` and `$1`.
+func expandEnv(b []byte) []byte {
+	return envRE.ReplaceAllFunc(b, func(m []byte) []byte {
+		return []byte(os.Getenv(string(m[2 : len(m)-1])))
+	})
+}
+
+// LoadHandlers reads a configuration file and builds handlers.
+func LoadHandlers(path string) ([]*bqloader.Handler, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Build()
+}
+
+// MustAddHandlers reads a configuration file and adds handlers into BQLoader.
+func MustAddHandlers(ctx context.Context, loader bqloader.BQLoader, path string) {
+	hs, err := LoadHandlers(path)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, h := range hs {
+		loader.MustAddHandler(ctx, h)
+	}
+}
+
+// Build builds handlers.
+func (c *Config) Build() ([]*bqloader.Handler, error) {
+	hs := make([]*bqloader.Handler, 0, len(c.Handlers))
+
+	for i, hc := range c.Handlers {
+		h, err := hc.Build()
+		if err != nil {
+			return nil, xerrors.Errorf("handlers[%d] (%s): %w", i, hc.Name, err)
+		}
+
+		hs = append(hs, h)
+	}
+
+	return hs, nil
+}
@@ -0,0 +1,309 @@
+package config_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"go.nownabe.dev/bqloader"
+	"go.nownabe.dev/bqloader/config"
+)
+
+type testLoader struct {
+	result [][]string
+}
+
+func (l *testLoader) Load(_ context.Context, rs [][]string) error {
+	l.result = rs
+
+	return nil
+}
+
+type testExtractor struct {
+	source io.Reader
+}
+
+func (e *testExtractor) Extract(context.Context, bqloader.Event) (io.Reader, func(), error) {
+	return e.source, func() {}, nil
+}
+
+func run(t *testing.T, h *bqloader.Handler, name, source string) [][]string {
+	t.Helper()
+
+	tl := &testLoader{}
+	h.SetConcurrency(1)
+	if h.BatchSize == 0 {
+		h.BatchSize = 100
+	}
+	h.Loader = tl
+	h.Extractor = &testExtractor{source: bytes.NewBufferString(source)}
+
+	if err := h.Handle(context.Background(), bqloader.Event{Name: name, Bucket: "bucket"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return tl.result
+}
+
+func assertEqual(t *testing.T, expected [][]string, actual [][]string) {
+	t.Helper()
+
+	if len(expected) != len(actual) {
+		t.Fatalf("expected %d length, but %d: %v", len(expected), len(actual), actual)
+	}
+
+	for i := range expected {
+		if strings.Join(expected[i], ",") != strings.Join(actual[i], ",") {
+			t.Errorf("expected actual[%d] is %v, but %v", i, expected[i], actual[i])
+		}
+	}
+}
+
+const yamlConfig = `
+handlers:
+  - name: example
+    pattern: ^example/
+    parser:
+      type: csv
+    skipLeadingRows: 1
+    skipIfEmpty:
+      column: 0
+    columns:
+      - column: 0
+        transforms:
+          - type: date
+            from: 2006/01/02
+      - column: 2
+        transforms:
+          - type: trim
+          - type: clean_number
+      - constant: JPY
+      - objectPath: '/(\d{4}-\d{2})\.csv$'
+        transforms:
+          - type: date
+            from: 2006-01
+    destination:
+      project: ${CONFIG_TEST_PROJECT}
+      dataset: dataset
+      table: example
+      writeDisposition: truncate
+      partition:
+        objectPath: '/(\d{4}-\d{2})\.csv$'
+        from: 2006-01
+        type: month
+    notifier:
+      type: slack
+      channel: "#channel"
+      token: token
+  - name: named
+    pattern: ^named/
+    parser:
+      type: header_csv
+    columns:
+      - name: 金額
+      - name: 日付
+    destination:
+      project: project
+      dataset: dataset
+      table: named
+      schema:
+        - name: amount
+          type: INTEGER
+          mode: REQUIRED
+        - name: date
+          type: STRING
+          description: payment date
+      timePartitioning:
+        type: month
+      clustering: [amount]
+  - name: contrib
+    contrib: SMBCStatement
+    pattern: ^smbc/
+    destination:
+      project: project
+      dataset: dataset
+      table: smbc
+      method: storage_write_pending
+    retry:
+      maxAttempts: 5
+      initialBackoff: 500ms
+`
+
+func Test_Parse(t *testing.T) {
+	t.Setenv("CONFIG_TEST_PROJECT", "myproject")
+
+	c, err := config.Parse([]byte(yamlConfig))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	hs, err := c.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(hs) != 3 {
+		t.Fatalf("expected 3 handlers, but %d", len(hs))
+	}
+
+	h := hs[0]
+	if h.Name != "example" || h.Project != "myproject" || h.Table != "example" {
+		t.Errorf("unexpected handler: %+v", h)
+	}
+	if h.StreamParser == nil || h.Parser != nil {
+		t.Errorf("csv parser should be a stream parser")
+	}
+	if _, ok := h.Notifier.(*bqloader.SlackNotifier); !ok {
+		t.Errorf("notifier should be SlackNotifier, but %T", h.Notifier)
+	}
+
+	actual := run(t, h, "example/2022-07.csv", "date,desc,amount\n2022/06/19,foo,\" 1,760\"\n,bar,0\n2022/06/20,baz,-129\n")
+	assertEqual(t, [][]string{
+		{"2022-06-19", "1760", "JPY", "2022-07-01"},
+		{"2022-06-20", "-129", "JPY", "2022-07-01"},
+	}, actual)
+
+	actual = run(t, hs[1], "named/a.csv", "日付,金額\n2022/06/19,100\n")
+	assertEqual(t, [][]string{{"100", "2022/06/19"}}, actual)
+
+	if hs[0].LoadMethod != bqloader.LoadJob {
+		t.Errorf("default load method should be LoadJob, but %v", hs[0].LoadMethod)
+	}
+
+	if hs[0].WriteDisposition != bigquery.WriteTruncate {
+		t.Errorf("write disposition should be WriteTruncate, but %v", hs[0].WriteDisposition)
+	}
+
+	p, err := hs[0].Partitioner(context.Background(), bqloader.Event{Name: "example/2022-07.csv"})
+	if err != nil || p != "202207" {
+		t.Errorf("partition should be 202207, but %q (%v)", p, err)
+	}
+
+	if hs[2].Name != "contrib" || hs[2].Table != "smbc" || !hs[2].Pattern.MatchString("smbc/a.csv") ||
+		hs[2].LoadMethod != bqloader.StorageWritePending {
+		t.Errorf("unexpected contrib handler: %+v", hs[2])
+	}
+
+	if p := hs[2].RetryPolicy; p == nil || p.MaxAttempts != 5 || p.InitialBackoff != 500*time.Millisecond ||
+		p.MaxBackoff != bqloader.DefaultRetryPolicy().MaxBackoff {
+		t.Errorf("unexpected retry policy: %+v", p)
+	}
+
+	if s := hs[1].Schema; len(s) != 2 || s[0].Name != "amount" || s[0].Type != bigquery.IntegerFieldType ||
+		!s[0].Required || s[1].Required || s[1].Description != "payment date" {
+		t.Errorf("unexpected schema: %+v", s)
+	}
+
+	if tp := hs[1].TimePartitioning; tp == nil || tp.Type != bigquery.MonthPartitioningType {
+		t.Errorf("unexpected time partitioning: %+v", tp)
+	}
+
+	if c := hs[1].Clustering; c == nil || len(c.Fields) != 1 || c.Fields[0] != "amount" {
+		t.Errorf("unexpected clustering: %+v", c)
+	}
+
+	if hs[0].RetryPolicy != nil {
+		t.Errorf("retry policy should be nil by default, but %+v", hs[0].RetryPolicy)
+	}
+}
+
+func Test_Parse_Dollar(t *testing.T) {
+	t.Setenv("CONFIG_TEST_TABLE", "dollar")
+	t.Setenv("abc", "expanded")
+
+	c, err := config.Parse([]byte(`
+handlers:
+  - name: dollar
+    pattern: '^dollar/$abc|\.csv$'
+    columns:
+      - objectPath: '^(\w+)/$*$1'
+    destination:
+      table: ${CONFIG_TEST_TABLE}
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	hc := c.Handlers[0]
+
+	if hc.Pattern != `^dollar/$abc|\.csv$` {
+		t.Errorf("pattern should be kept as is, but %q", hc.Pattern)
+	}
+	if hc.Columns[0].ObjectPath != `^(\w+)/$*$1` {
+		t.Errorf("objectPath should be kept as is, but %q", hc.Columns[0].ObjectPath)
+	}
+	if hc.Destination.Table != "dollar" {
+		t.Errorf("${CONFIG_TEST_TABLE} should be expanded, but %q", hc.Destination.Table)
+	}
+}
+
+func Test_Load_JSON(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	body := `{"handlers": [{"name": "json", "pattern": "^json/", "encoding": "shift_jis",
+		"destination": {"project": "p", "dataset": "d", "table": "t"}}]}`
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	hs, err := config.LoadHandlers(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(hs) != 1 || hs[0].Name != "json" || hs[0].Encoding == nil {
+		t.Errorf("unexpected handlers: %+v", hs)
+	}
+}
+
+func Test_Build_Error(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"no name":             `{name: "", pattern: "^a/", destination: {table: t}}`,
+		"no destination":      `{name: a, pattern: "^a/"}`,
+		"invalid pattern":     `{name: a, pattern: "(", destination: {table: t}}`,
+		"unknown contrib":     `{name: a, contrib: Unknown, pattern: "^a/", destination: {table: t}}`,
+		"unknown encoding":    `{name: a, pattern: "^a/", encoding: unknown, destination: {table: t}}`,
+		"unknown parser":      `{name: a, pattern: "^a/", parser: {type: xml}, destination: {table: t}}`,
+		"unknown notifier":    `{name: a, pattern: "^a/", notifier: {type: mail}, destination: {table: t}}`,
+		"two sources":         `{name: a, pattern: "^a/", columns: [{column: 0, constant: x}], destination: {table: t}}`,
+		"no source":           `{name: a, pattern: "^a/", columns: [{transforms: [{type: trim}]}], destination: {table: t}}`,
+		"name without header": `{name: a, pattern: "^a/", columns: [{name: x}], destination: {table: t}}`,
+		"unknown transform": `{name: a, pattern: "^a/", columns: [{column: 0, transforms: [{type: x}]}],
+			destination: {table: t}}`,
+		"no capturing group":  `{name: a, pattern: "^a/", columns: [{objectPath: "a"}], destination: {table: t}}`,
+		"unknown method":      `{name: a, pattern: "^a/", destination: {table: t, method: streaming}}`,
+		"unknown disposition": `{name: a, pattern: "^a/", destination: {table: t, writeDisposition: empty}}`,
+		"unknown partition type": `{name: a, pattern: "^a/",
+			destination: {table: t, partition: {objectPath: "(a)", from: "2006", type: week}}}`,
+		"clustering without schema": `{name: a, pattern: "^a/", destination: {table: t, clustering: [a]}}`,
+		"unknown partitioning type": `{name: a, pattern: "^a/",
+			destination: {table: t, schema: [{name: a, type: DATE}], timePartitioning: {type: week}}}`,
+		"partition without capturing group": `{name: a, pattern: "^a/",
+			destination: {table: t, partition: {objectPath: "a", from: "2006", type: year}}}`,
+	}
+
+	for name, body := range cases {
+		body := body
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := config.Parse([]byte("handlers: [" + body + "]"))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if _, err := c.Build(); err == nil {
+				t.Errorf("expected error but no error occurred")
+			}
+		})
+	}
+}
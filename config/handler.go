@@ -0,0 +1,287 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"go.nownabe.dev/bqloader"
+	"go.nownabe.dev/bqloader/contrib/handlers"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/xerrors"
+)
+
+var (
+	errNoName        = errors.New("name is required")
+	errNoPattern     = errors.New("pattern is required")
+	errNoDestination = errors.New("destination is required")
+)
+
+// Build builds a handler.
+func (c *HandlerConfig) Build() (*bqloader.Handler, error) {
+	if c.Name == "" {
+		return nil, errNoName
+	}
+
+	if c.Pattern == "" {
+		return nil, errNoPattern
+	}
+
+	if c.Destination == nil {
+		return nil, errNoDestination
+	}
+
+	pattern, err := regexp.Compile(c.Pattern)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid pattern: %w", err)
+	}
+
+	notifier, err := c.Notifier.build()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid notifier: %w", err)
+	}
+
+	if c.Contrib != "" {
+		h, err := c.buildContrib(notifier)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.Destination.apply(h); err != nil {
+			return nil, xerrors.Errorf("invalid destination: %w", err)
+		}
+		h.RetryPolicy = c.Retry.build()
+
+		return h, nil
+	}
+
+	enc, err := buildEncoding(c.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &bqloader.Handler{
+		Name:             c.Name,
+		Pattern:          pattern,
+		Encoding:         enc,
+		Notifier:         notifier,
+		SkipLeadingRows:  c.SkipLeadingRows,
+		AppendLineNumber: c.AppendLineNumber,
+		BatchSize:        c.BatchSize,
+		Project:          c.Destination.Project,
+		Dataset:          c.Destination.Dataset,
+		Table:            c.Destination.Table,
+	}
+
+	if err := c.Destination.apply(h); err != nil {
+		return nil, xerrors.Errorf("invalid destination: %w", err)
+	}
+
+	switch {
+	case c.MaxBadRows > 0 && c.MaxBadRatio > 0:
+		return nil, xerrors.New("maxBadRows and maxBadRatio are exclusive")
+	case c.MaxBadRows > 0:
+		h.ErrorPolicy = bqloader.SkipBadRows(c.MaxBadRows)
+	case c.MaxBadRatio > 0:
+		h.ErrorPolicy = bqloader.SkipBadRowsRatio(c.MaxBadRatio)
+	}
+
+	h.RetryPolicy = c.Retry.build()
+
+	if err := c.Parser.apply(h); err != nil {
+		return nil, xerrors.Errorf("invalid parser: %w", err)
+	}
+
+	if err := c.applyProjector(h); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (c *HandlerConfig) buildContrib(notifier bqloader.Notifier) (*bqloader.Handler, error) {
+	constructor, ok := handlers.Lookup(c.Contrib)
+	if !ok {
+		return nil, xerrors.Errorf("unknown contrib handler: %s", c.Contrib)
+	}
+
+	t := handlers.Table{Project: c.Destination.Project, Dataset: c.Destination.Dataset, Table: c.Destination.Table}
+
+	return constructor(c.Name, c.Pattern, t, notifier), nil
+}
+
+var partitioningTypes = map[string]bigquery.TimePartitioningType{
+	"hour":  bigquery.HourPartitioningType,
+	"day":   bigquery.DayPartitioningType,
+	"month": bigquery.MonthPartitioningType,
+	"year":  bigquery.YearPartitioningType,
+}
+
+func (c *DestinationConfig) apply(h *bqloader.Handler) error {
+	switch c.Method {
+	case "", "load_job":
+		h.LoadMethod = bqloader.LoadJob
+	case "storage_write_committed":
+		h.LoadMethod = bqloader.StorageWriteCommitted
+	case "storage_write_pending":
+		h.LoadMethod = bqloader.StorageWritePending
+	default:
+		return xerrors.Errorf("unknown method: %s", c.Method)
+	}
+
+	switch c.WriteDisposition {
+	case "", "append":
+		h.WriteDisposition = bigquery.WriteAppend
+	case "truncate":
+		h.WriteDisposition = bigquery.WriteTruncate
+	default:
+		return xerrors.Errorf("unknown writeDisposition: %s", c.WriteDisposition)
+	}
+
+	if err := c.applySchema(h); err != nil {
+		return err
+	}
+
+	if c.Partition == nil {
+		return nil
+	}
+
+	re, err := regexp.Compile(c.Partition.ObjectPath)
+	if err != nil {
+		return xerrors.Errorf("invalid partition objectPath: %w", err)
+	}
+	if re.NumSubexp() == 0 {
+		return xerrors.Errorf("partition objectPath must have a capturing group: %s", c.Partition.ObjectPath)
+	}
+
+	typ, ok := partitioningTypes[c.Partition.Type]
+	if !ok {
+		return xerrors.Errorf("unknown partition type: %s", c.Partition.Type)
+	}
+
+	h.Partitioner = bqloader.PartitionFromName(re, c.Partition.From, typ)
+
+	return nil
+}
+
+func (c *DestinationConfig) applySchema(h *bqloader.Handler) error {
+	if len(c.Schema) == 0 {
+		if c.TimePartitioning != nil || len(c.Clustering) > 0 {
+			return xerrors.New("timePartitioning and clustering require schema")
+		}
+		return nil
+	}
+
+	b, err := json.Marshal(c.Schema)
+	if err != nil {
+		return xerrors.Errorf("failed to marshal schema: %w", err)
+	}
+
+	schema, err := bigquery.SchemaFromJSON(b)
+	if err != nil {
+		return xerrors.Errorf("invalid schema: %w", err)
+	}
+	h.Schema = schema
+
+	if tp := c.TimePartitioning; tp != nil {
+		typ, ok := partitioningTypes[strings.ToLower(tp.Type)]
+		if !ok {
+			return xerrors.Errorf("unknown timePartitioning type: %s", tp.Type)
+		}
+		h.TimePartitioning = &bigquery.TimePartitioning{Type: typ, Field: tp.Field}
+	}
+
+	if len(c.Clustering) > 0 {
+		h.Clustering = &bigquery.Clustering{Fields: c.Clustering}
+	}
+
+	return nil
+}
+
+func (c *RetryConfig) build() *bqloader.RetryPolicy {
+	if c == nil {
+		return nil
+	}
+
+	p := bqloader.DefaultRetryPolicy()
+
+	if c.MaxAttempts > 0 {
+		p.MaxAttempts = c.MaxAttempts
+	}
+	if c.InitialBackoff > 0 {
+		p.InitialBackoff = c.InitialBackoff
+	}
+	if c.MaxBackoff > 0 {
+		p.MaxBackoff = c.MaxBackoff
+	}
+
+	return p
+}
+
+func buildEncoding(name string) (encoding.Encoding, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, xerrors.Errorf("unknown encoding %s: %w", name, err)
+	}
+
+	if enc == unicode.UTF8 {
+		return nil, nil
+	}
+
+	return enc, nil
+}
+
+// named reports whether the parser provides a header to access columns by names.
+func (c *ParserConfig) named() bool {
+	return c != nil && c.Type == "header_csv"
+}
+
+func (c *ParserConfig) apply(h *bqloader.Handler) error {
+	if c == nil {
+		h.StreamParser = bqloader.CSVStreamParser()
+		return nil
+	}
+
+	switch c.Type {
+	case "", "csv":
+		h.StreamParser = bqloader.CSVStreamParser()
+	case "header_csv":
+		h.StreamParser = bqloader.HeaderCSVParser()
+	case "partial_csv":
+		sep := c.Separator
+		if sep == "" {
+			sep = "\n"
+		}
+		h.Parser = handlers.PartialCSVParser(c.SkipHeadRows, c.SkipTailRows, sep)
+	default:
+		return xerrors.Errorf("unknown parser type: %s", c.Type)
+	}
+
+	return nil
+}
+
+func (c *NotifierConfig) build() (bqloader.Notifier, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	switch c.Type {
+	case "slack":
+		return &bqloader.SlackNotifier{
+			Channel:   c.Channel,
+			Token:     c.Token,
+			IconEmoji: c.IconEmoji,
+			Username:  c.Username,
+		}, nil
+	default:
+		return nil, xerrors.Errorf("unknown notifier type: %s", c.Type)
+	}
+}
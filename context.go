@@ -3,6 +3,8 @@ package bqloader
 import (
 	"context"
 	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
 )
 
 type contextKey string
@@ -10,6 +12,10 @@ type contextKey string
 const (
 	startedTimeKey        contextKey = "startedTime"
 	handlerStartedTimeKey contextKey = "handlerStartedTime"
+	statsKey              contextKey = "stats"
+	partitionKey          contextKey = "partition"
+	cloudEventKey         contextKey = "cloudEvent"
+	pushRequestKey        contextKey = "pushRequest"
 )
 
 func withStartedTime(ctx context.Context) context.Context {
@@ -31,3 +37,33 @@ func handlerStartedTimeFrom(ctx context.Context) (time.Time, bool) {
 
 	return t, ok
 }
+
+func withPartition(ctx context.Context, p string) context.Context {
+	return context.WithValue(ctx, partitionKey, p)
+}
+
+func partitionFrom(ctx context.Context) (string, bool) {
+	p, ok := ctx.Value(partitionKey).(string)
+
+	return p, ok
+}
+
+func withCloudEvent(ctx context.Context, ce cloudevents.Event) context.Context {
+	return context.WithValue(ctx, cloudEventKey, ce)
+}
+
+func cloudEventFrom(ctx context.Context) (cloudevents.Event, bool) {
+	ce, ok := ctx.Value(cloudEventKey).(cloudevents.Event)
+
+	return ce, ok
+}
+
+func withPushRequest(ctx context.Context, p *pushRequest) context.Context {
+	return context.WithValue(ctx, pushRequestKey, p)
+}
+
+func pushRequestFrom(ctx context.Context) (*pushRequest, bool) {
+	p, ok := ctx.Value(pushRequestKey).(*pushRequest)
+
+	return p, ok
+}
@@ -2,71 +2,38 @@ package handlers
 
 import (
 	"context"
-	"errors"
-	"io"
 	"regexp"
 	"time"
 
-	"github.com/extrame/xls"
-	"gitlab.com/osaki-lab/iowrapper"
+	"cloud.google.com/go/bigquery"
 	"go.nownabe.dev/bqloader"
 	"golang.org/x/xerrors"
 )
 
-var (
-	errAMEXStatementNoSheet = errors.New("no sheet found")
-)
+// AMEXStatementSchema is the schema of rows projected by AMEXStatement and AMEXStatementCSV.
+var AMEXStatementSchema = bigquery.Schema{
+	{Name: "date", Type: bigquery.DateFieldType, Required: true, Description: "ご利用日"},
+	{Name: "processed_date", Type: bigquery.DateFieldType, Required: true, Description: "データ処理日"},
+	{Name: "description", Type: bigquery.StringFieldType, Required: true, Description: "ご利用内容"},
+	{Name: "member_name", Type: bigquery.StringFieldType, Description: "カード会員様名"},
+	{Name: "amount", Type: bigquery.NumericFieldType, Required: true, Description: "金額"},
+	{Name: "foreign_amount", Type: bigquery.StringFieldType, Description: "海外通貨利用金額"},
+	{Name: "exchange_rate", Type: bigquery.NumericFieldType, Description: "換算レート"},
+	{Name: "additional_info", Type: bigquery.StringFieldType, Description: "追加情報"},
+	{Name: "payment_month", Type: bigquery.DateFieldType, Required: true, Description: "支払い月"},
+}
 
 // AMEXStatement build a *bqloader.Handler for statements of AMEX (American Express).
 // To add column of payment month, keep the file name as the payment month like '2022-07.xls'.
 func AMEXStatement(name, pattern string, table Table, notifier bqloader.Notifier) *bqloader.Handler {
 	var monthKey contextKey = "month"
 
-	getRow := func(sheet *xls.WorkSheet, row int) (r *xls.Row, ok bool) {
-		defer func() { recover() }()
-
-		r = nil
-		ok = false
-
-		return sheet.Row(row), true
-	}
-
 	dateRE := regexp.MustCompile(`^\d\d\d\d/\d\d/\d\d$`)
 
-	parser := func(_ context.Context, r io.Reader) ([][]string, error) {
-		wb, err := xls.OpenReader(iowrapper.NewSeeker(r), "utf-8")
-		if err != nil {
-			return nil, xerrors.Errorf("failed to open xls file: %w", err)
-		}
-
-		sheet := wb.GetSheet(0)
-		if sheet == nil {
-			return nil, errAMEXStatementNoSheet
-		}
-
-		records := [][]string{}
-
-		for i := 0; i <= int(sheet.MaxRow); i++ {
-			row, ok := getRow(sheet, i)
-			if !ok {
-				continue
-			}
-
-			if val := row.Col(row.FirstCol()); !dateRE.Match([]byte(val)) {
-				continue
-			}
-
-			record := []string{}
-
-			for colNum := row.FirstCol(); colNum < row.LastCol(); colNum++ {
-				record = append(record, row.Col(colNum))
-			}
-
-			records = append(records, record)
-		}
-
-		return records, nil
-	}
+	// Only statement rows start with dates.
+	parser := XLSParser(XLSRowFilter(func(r []string) bool {
+		return dateRE.MatchString(r[0])
+	}))
 
 	filenameRE := regexp.MustCompile(`/(\d\d\d\d-\d\d)\.xls$`)
 
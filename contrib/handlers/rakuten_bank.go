@@ -5,11 +5,20 @@ import (
 	"regexp"
 	"time"
 
+	"cloud.google.com/go/bigquery"
 	"go.nownabe.dev/bqloader"
 	"golang.org/x/text/encoding/japanese"
 	"golang.org/x/xerrors"
 )
 
+// RakutenBankStatementSchema is the schema of rows projected by RakutenBankStatement.
+var RakutenBankStatementSchema = bigquery.Schema{
+	{Name: "date", Type: bigquery.DateFieldType, Required: true, Description: "取引日"},
+	{Name: "amount", Type: bigquery.NumericFieldType, Required: true, Description: "入出金 (円)"},
+	{Name: "balance", Type: bigquery.NumericFieldType, Required: true, Description: "残高 (円)"},
+	{Name: "description", Type: bigquery.StringFieldType, Description: "入出金先内容"},
+}
+
 // RakutenBankStatement build a handler for statements for Rakuten Bank (楽天銀行 入出金明細).
 func RakutenBankStatement(name, pattern string, table Table, notifier bqloader.Notifier) *bqloader.Handler {
 	projector := func(ctx context.Context, r []string) ([]string, error) {
@@ -7,10 +7,26 @@ import (
 	"regexp"
 	"time"
 
+	"cloud.google.com/go/bigquery"
 	"go.nownabe.dev/bqloader"
 	"golang.org/x/xerrors"
 )
 
+// RakutenCardStatementSchema is the schema of rows projected by RakutenCardStatement.
+var RakutenCardStatementSchema = bigquery.Schema{
+	{Name: "date", Type: bigquery.DateFieldType, Required: true, Description: "利用日"},
+	{Name: "description", Type: bigquery.StringFieldType, Required: true, Description: "利用店名・商品名"},
+	{Name: "user", Type: bigquery.StringFieldType, Description: "利用者"},
+	{Name: "payment_method", Type: bigquery.StringFieldType, Description: "支払方法"},
+	{Name: "usage_amount", Type: bigquery.NumericFieldType, Description: "利用金額"},
+	{Name: "fee", Type: bigquery.NumericFieldType, Description: "支払手数料"},
+	{Name: "total_amount", Type: bigquery.NumericFieldType, Description: "支払総額"},
+	{Name: "payment_amount", Type: bigquery.NumericFieldType, Description: "当月支払金額"},
+	{Name: "carried_over", Type: bigquery.NumericFieldType, Description: "翌月繰越残高"},
+	{Name: "new_sign", Type: bigquery.StringFieldType, Description: "新規サイン"},
+	{Name: "payment_month", Type: bigquery.DateFieldType, Required: true, Description: "支払い月"},
+}
+
 // RakutenCardStatement build a handler for statements of Rakuten Card (楽天カード 明細).
 // To add column of payment month, keep the file name when you downloaded it.
 func RakutenCardStatement(name, pattern string, table Table, notifier bqloader.Notifier) *bqloader.Handler {
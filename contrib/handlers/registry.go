@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"sort"
+
+	"cloud.google.com/go/bigquery"
+	"go.nownabe.dev/bqloader"
+)
+
+// Constructor is a function to build a pre-configured handler
+// given handler name, a pattern to file path on Cloud Storage, a BigQuery table and a notifier.
+type Constructor func(name, pattern string, table Table, notifier bqloader.Notifier) *bqloader.Handler
+
+var registry = map[string]Constructor{
+	"AMEXStatement":                       AMEXStatement,
+	"AMEXStatementCSV":                    AMEXStatementCSV,
+	"RakutenBankStatement":                RakutenBankStatement,
+	"RakutenCardStatement":                RakutenCardStatement,
+	"SBISecuritiesGlobalBankingStatement": SBISecuritiesGlobalBankingStatement,
+	"SBISecuritiesGlobalExecutionHistory": SBISecuritiesGlobalExecutionHistory,
+	"SBISumishinNetBankStatement":         SBISumishinNetBankStatement,
+	"SMBCCardStatement":                   SMBCCardStatement,
+	"SMBCStatement":                       SMBCStatement,
+	"SonyBankStatement":                   SonyBankStatement,
+	"ZenginTransfer":                      ZenginTransfer,
+}
+
+var schemas = map[string]bigquery.Schema{
+	"AMEXStatement":                       AMEXStatementSchema,
+	"AMEXStatementCSV":                    AMEXStatementSchema,
+	"RakutenBankStatement":                RakutenBankStatementSchema,
+	"RakutenCardStatement":                RakutenCardStatementSchema,
+	"SBISecuritiesGlobalBankingStatement": SBISecuritiesGlobalBankingStatementSchema,
+	"SBISecuritiesGlobalExecutionHistory": SBISecuritiesGlobalExecutionHistorySchema,
+	"SBISumishinNetBankStatement":         SBISumishinNetBankStatementSchema,
+	"SMBCCardStatement":                   SMBCCardStatementSchema,
+	"SMBCStatement":                       SMBCStatementSchema,
+	"SonyBankStatement":                   SonyBankStatementSchema,
+	"ZenginTransfer":                      ZenginTransferSchema,
+}
+
+// Lookup returns the constructor of the pre-configured handler named name such as "SMBCStatement".
+func Lookup(name string) (Constructor, bool) {
+	c, ok := registry[name]
+
+	return c, ok
+}
+
+// Names returns names of all pre-configured handlers in alphabetical order.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// LookupSchema returns the schema of rows projected by the pre-configured handler named name.
+func LookupSchema(name string) (bigquery.Schema, bool) {
+	s, ok := schemas[name]
+
+	return s, ok
+}
@@ -0,0 +1,28 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"go.nownabe.dev/bqloader/contrib/handlers"
+)
+
+func Test_Lookup(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range handlers.Names() {
+		c, ok := handlers.Lookup(name)
+		if !ok {
+			t.Errorf("%s should be found", name)
+			continue
+		}
+
+		table := handlers.Table{Project: "p", Dataset: "d", Table: "t"}
+		if h := c("name", "^path_to/", table, nil); h == nil {
+			t.Errorf("%s should build a handler", name)
+		}
+	}
+
+	if _, ok := handlers.Lookup("Unknown"); ok {
+		t.Errorf("Unknown should not be found")
+	}
+}
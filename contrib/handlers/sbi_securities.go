@@ -5,11 +5,22 @@ import (
 	"regexp"
 	"time"
 
+	"cloud.google.com/go/bigquery"
 	"go.nownabe.dev/bqloader"
 	"golang.org/x/text/encoding/japanese"
 	"golang.org/x/xerrors"
 )
 
+// SBISecuritiesGlobalBankingStatementSchema is the schema of rows projected by SBISecuritiesGlobalBankingStatement.
+var SBISecuritiesGlobalBankingStatementSchema = bigquery.Schema{
+	{Name: "date", Type: bigquery.DateFieldType, Required: true, Description: "入出金日"},
+	{Name: "deal_type", Type: bigquery.StringFieldType, Required: true, Description: "取引"},
+	{Name: "currency", Type: bigquery.StringFieldType, Required: true, Description: "通貨"},
+	{Name: "description", Type: bigquery.StringFieldType, Description: "摘要"},
+	{Name: "withdrawal_amount", Type: bigquery.NumericFieldType, Description: "出金額"},
+	{Name: "deposit_amount", Type: bigquery.NumericFieldType, Description: "入金額"},
+}
+
 // SBISecuritiesGlobalBankingStatement build a handler for banking statement of SBI Securities Global (SBI証券 外国株式 入出金明細).
 func SBISecuritiesGlobalBankingStatement(name, pattern string, table Table, notifier bqloader.Notifier) *bqloader.Handler {
 	projector := func(_ context.Context, r []string) ([]string, error) {
@@ -56,6 +67,22 @@ func SBISecuritiesGlobalBankingStatement(name, pattern string, table Table, noti
 	}
 }
 
+// SBISecuritiesGlobalExecutionHistorySchema is the schema of rows projected by SBISecuritiesGlobalExecutionHistory.
+var SBISecuritiesGlobalExecutionHistorySchema = bigquery.Schema{
+	{Name: "contract_date", Type: bigquery.DateFieldType, Required: true, Description: "国内約定日"},
+	{Name: "name", Type: bigquery.StringFieldType, Required: true, Description: "銘柄名"},
+	{Name: "code", Type: bigquery.StringFieldType, Required: true, Description: "銘柄コード"},
+	{Name: "market", Type: bigquery.StringFieldType, Required: true, Description: "市場"},
+	{Name: "stock_type", Type: bigquery.StringFieldType, Required: true, Description: "商品区分"},
+	{Name: "order_type", Type: bigquery.StringFieldType, Required: true, Description: "注文種別"},
+	{Name: "deal_type", Type: bigquery.StringFieldType, Required: true, Description: "取引"},
+	{Name: "account_type", Type: bigquery.StringFieldType, Required: true, Description: "預り区分"},
+	{Name: "quantity", Type: bigquery.NumericFieldType, Required: true, Description: "約定数量"},
+	{Name: "unit_price", Type: bigquery.NumericFieldType, Required: true, Description: "約定単価 (USD)"},
+	{Name: "delivery_date", Type: bigquery.DateFieldType, Required: true, Description: "国内受渡日"},
+	{Name: "delivery_amount", Type: bigquery.NumericFieldType, Required: true, Description: "受渡金額 (JPY)"},
+}
+
 // SBISecuritiesGlobalExecutionHistory build a handler for execution history of SBI Securities Global (SBI証券 外国株式 約定履歴).
 func SBISecuritiesGlobalExecutionHistory(name, pattern string, table Table, notifier bqloader.Notifier) *bqloader.Handler {
 	projector := func(_ context.Context, r []string) ([]string, error) {
@@ -5,11 +5,22 @@ import (
 	"regexp"
 	"time"
 
+	"cloud.google.com/go/bigquery"
 	"go.nownabe.dev/bqloader"
 	"golang.org/x/text/encoding/japanese"
 	"golang.org/x/xerrors"
 )
 
+// SBISumishinNetBankStatementSchema is the schema of rows projected by SBISumishinNetBankStatement.
+var SBISumishinNetBankStatementSchema = bigquery.Schema{
+	{Name: "date", Type: bigquery.DateFieldType, Required: true, Description: "日付"},
+	{Name: "description", Type: bigquery.StringFieldType, Required: true, Description: "内容"},
+	{Name: "withdrawal_amount", Type: bigquery.NumericFieldType, Description: "出金金額 (円)"},
+	{Name: "deposit_amount", Type: bigquery.NumericFieldType, Description: "入金金額 (円)"},
+	{Name: "balance", Type: bigquery.NumericFieldType, Required: true, Description: "残高 (円)"},
+	{Name: "memo", Type: bigquery.StringFieldType, Description: "メモ"},
+}
+
 // SBISumishinNetBankStatement build a handler for statements of SBI bank (住信SBIネット銀行).
 func SBISumishinNetBankStatement(name, pattern string, table Table, notifier bqloader.Notifier) *bqloader.Handler {
 	projector := func(_ context.Context, r []string) ([]string, error) {
@@ -0,0 +1,183 @@
+package handlers_test
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"go.nownabe.dev/bqloader"
+	"go.nownabe.dev/bqloader/contrib/handlers"
+)
+
+func Test_SchemaConformance(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		handler string
+		path    string
+		name    string
+	}{
+		{handler: "AMEXStatement", path: "testdata/amex_statement.xls", name: "path_to/2022-07.xls"},
+		{handler: "AMEXStatementCSV", path: "testdata/amex_statement.csv", name: "path_to/2023-08.csv"},
+		{handler: "RakutenBankStatement", path: "testdata/rakuten_bank_statement.csv", name: "path_to/rakuten_bank_statement.csv"},
+		{handler: "RakutenCardStatement", path: "testdata/rakuten_card_statement.csv", name: "path_to/enavi202012(1234).csv"},
+		{
+			handler: "SBISecuritiesGlobalBankingStatement",
+			path:    "testdata/sbi_securities_global_banking_statement.csv",
+			name:    "path_to/sbi_securities_global_banking_statement.csv",
+		},
+		{
+			handler: "SBISecuritiesGlobalExecutionHistory",
+			path:    "testdata/sbi_securities_global_execution_history.csv",
+			name:    "path_to/sbi_securities_global_execution_history.csv",
+		},
+		{
+			handler: "SBISumishinNetBankStatement",
+			path:    "testdata/sbi_sumishin_net_bank_statement.csv",
+			name:    "path_to/sbi_sumishin_net_bank_statement.csv",
+		},
+		{handler: "SMBCCardStatement", path: "testdata/smbc_card_statement.csv", name: "path_to/202012.csv"},
+		{handler: "SMBCCardStatement", path: "testdata/smbc_card_statement2.csv", name: "path_to/202212.csv"},
+		{handler: "SMBCStatement", path: "testdata/smbc_statement.csv", name: "path_to/smbc_statement.csv"},
+		{handler: "SMBCStatement", path: "testdata/smbc_statement2.csv", name: "path_to/smbc_statement2.csv"},
+		{handler: "SonyBankStatement", path: "testdata/sony_bank_statement.csv", name: "path_to/sony_bank_statement.csv"},
+		{handler: "ZenginTransfer", path: "testdata/zengin_transfer.txt", name: "path_to/zengin_transfer.txt"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.path, func(t *testing.T) {
+			t.Parallel()
+
+			constructor, ok := handlers.Lookup(c.handler)
+			if !ok {
+				t.Fatalf("%s should be found", c.handler)
+			}
+
+			schema, ok := handlers.LookupSchema(c.handler)
+			if !ok {
+				t.Fatalf("schema of %s should be found", c.handler)
+			}
+
+			h, tl := buildTestHandler(t, c.path, constructor)
+
+			e := bqloader.Event{Name: c.name, Bucket: "bucket"}
+			if err := h.Handle(context.Background(), e); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if len(tl.result) == 0 {
+				t.Fatalf("no rows are projected")
+			}
+
+			for i, r := range tl.result {
+				assertConform(t, schema, i, r)
+			}
+		})
+	}
+}
+
+func Test_LookupSchema(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range handlers.Names() {
+		if _, ok := handlers.LookupSchema(name); !ok {
+			t.Errorf("schema of %s should be found", name)
+		}
+	}
+
+	if _, ok := handlers.LookupSchema("Unknown"); ok {
+		t.Errorf("schema of Unknown should not be found")
+	}
+}
+
+// Test_ExampleSchemas ensures that table definitions in the example match schemas of handlers.
+func Test_ExampleSchemas(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"sbi_bank.json":  "SBISumishinNetBankStatement",
+		"sbi_sec.json":   "SBISecuritiesGlobalExecutionHistory",
+		"smbc_card.json": "SMBCCardStatement",
+	}
+
+	for file, handler := range cases {
+		file, handler := file, handler
+		t.Run(file, func(t *testing.T) {
+			t.Parallel()
+
+			body, err := os.ReadFile(filepath.Join("../../examples/pre_configured_handlers", file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := bigquery.SchemaFromJSON(body)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			expected, ok := handlers.LookupSchema(handler)
+			if !ok {
+				t.Fatalf("schema of %s should be found", handler)
+			}
+
+			if len(expected) != len(actual) {
+				t.Fatalf("expected %d fields, but %d", len(expected), len(actual))
+			}
+
+			for i, e := range expected {
+				a := actual[i]
+				if e.Name != a.Name || e.Type != a.Type || e.Required != a.Required || e.Description != a.Description {
+					t.Errorf("expected field %d is %+v, but %+v", i, e, a)
+				}
+			}
+		})
+	}
+}
+
+func assertConform(t *testing.T, schema bigquery.Schema, i int, r []string) {
+	t.Helper()
+
+	if len(schema) != len(r) {
+		t.Errorf("row %d has %d columns, but schema has %d", i, len(r), len(schema))
+		return
+	}
+
+	for j, f := range schema {
+		v := r[j]
+
+		if v == "" {
+			if f.Required {
+				t.Errorf("row %d: %s is required, but empty", i, f.Name)
+			}
+			continue
+		}
+
+		var err error
+
+		switch f.Type {
+		case bigquery.DateFieldType:
+			_, err = time.Parse("2006-01-02", v)
+		case bigquery.IntegerFieldType:
+			_, err = strconv.ParseInt(v, 10, 64)
+		case bigquery.FloatFieldType:
+			_, err = strconv.ParseFloat(v, 64)
+		case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+			if _, ok := new(big.Rat).SetString(v); !ok {
+				t.Errorf("row %d: %s should be %s, but '%s'", i, f.Name, f.Type, v)
+			}
+		case bigquery.StringFieldType:
+		default:
+			t.Errorf("row %d: %s has unexpected type %s", i, f.Name, f.Type)
+		}
+
+		if err != nil {
+			t.Errorf("row %d: %s should be %s, but '%s': %v", i, f.Name, f.Type, v, err)
+		}
+	}
+}
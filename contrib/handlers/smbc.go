@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"cloud.google.com/go/bigquery"
 	"go.nownabe.dev/bqloader"
 	"golang.org/x/text/encoding/japanese"
 	"golang.org/x/xerrors"
@@ -40,6 +41,17 @@ func parseSMBCDate(date string) (time.Time, error) {
 	return time.Parse("2006.01.02", fmt.Sprintf("%d%s", wareki+rekiBase, date[3:9]))
 }
 
+// SMBCStatementSchema is the schema of rows projected by SMBCStatement.
+var SMBCStatementSchema = bigquery.Schema{
+	{Name: "date", Type: bigquery.DateFieldType, Required: true, Description: "年月日"},
+	{Name: "withdrawal_amount", Type: bigquery.NumericFieldType, Description: "お引出し"},
+	{Name: "deposit_amount", Type: bigquery.NumericFieldType, Description: "お預入れ"},
+	{Name: "description", Type: bigquery.StringFieldType, Description: "お取り扱い内容"},
+	{Name: "balance", Type: bigquery.NumericFieldType, Required: true, Description: "残高"},
+	{Name: "memo", Type: bigquery.StringFieldType, Description: "メモ"},
+	{Name: "label", Type: bigquery.StringFieldType, Description: "ラベル"},
+}
+
 // SMBCStatement builds a handler for statements for SMBC (三井住友銀行 入出金明細).
 func SMBCStatement(name, pattern string, t Table, n bqloader.Notifier) *bqloader.Handler {
 	projector := func(ctx context.Context, r []string) ([]string, error) {
@@ -50,6 +62,11 @@ func SMBCStatement(name, pattern string, t Table, n bqloader.Notifier) *bqloader
 		}
 		r[0] = t.Format("2006-01-02")
 
+		// Old statements don't have メモ and ラベル.
+		for len(r) < len(SMBCStatementSchema) {
+			r = append(r, "")
+		}
+
 		return r, nil
 	}
 
@@ -10,11 +10,24 @@ import (
 	"strings"
 	"time"
 
+	"cloud.google.com/go/bigquery"
 	"go.nownabe.dev/bqloader"
 	"golang.org/x/text/encoding/japanese"
 	"golang.org/x/xerrors"
 )
 
+// SMBCCardStatementSchema is the schema of rows projected by SMBCCardStatement.
+var SMBCCardStatementSchema = bigquery.Schema{
+	{Name: "date", Type: bigquery.DateFieldType, Required: true, Description: "ご利用日"},
+	{Name: "description", Type: bigquery.StringFieldType, Required: true, Description: "ご利用店名"},
+	{Name: "usage_amount", Type: bigquery.NumericFieldType, Description: "ご利用金額"},
+	{Name: "payment_section", Type: bigquery.StringFieldType, Description: "支払区分"},
+	{Name: "this_time", Type: bigquery.StringFieldType, Description: "今回回数"},
+	{Name: "payment_amount", Type: bigquery.NumericFieldType, Description: "お支払金額"},
+	{Name: "other", Type: bigquery.StringFieldType, Description: "その他"},
+	{Name: "payment_month", Type: bigquery.DateFieldType, Required: true, Description: "支払い月"},
+}
+
 // SMBCCardStatement build a *bqloader.Handler for statements of SMBC card (三井住友VISAカード).
 // To add column of payment month, keep the file name when you downloaded it.
 func SMBCCardStatement(name, pattern string, table Table, notifier bqloader.Notifier) *bqloader.Handler {
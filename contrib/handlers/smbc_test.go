@@ -56,9 +56,9 @@ func Test_SMBCStatement(t *testing.T) {
 	const csv = "testdata/smbc_statement.csv"
 
 	expected := [][]string{
-		{"2019-12-04", "10389", "", "カ)ビユ-カ-ド", "124001"},
-		{"2019-12-21", "", "160000", "振込　スミトモ タロウ", "284001"},
-		{"2019-12-26", "80980", "", "ミツイスミトモカ-ド (カ", "203021"},
+		{"2019-12-04", "10389", "", "カ)ビユ-カ-ド", "124001", "", ""},
+		{"2019-12-21", "", "160000", "振込　スミトモ タロウ", "284001", "", ""},
+		{"2019-12-26", "80980", "", "ミツイスミトモカ-ド (カ", "203021", "", ""},
 	}
 
 	h, tl := buildTestHandler(t, csv, handlers.SMBCStatement)
@@ -5,11 +5,22 @@ import (
 	"regexp"
 	"time"
 
+	"cloud.google.com/go/bigquery"
 	"go.nownabe.dev/bqloader"
 	"golang.org/x/text/encoding/japanese"
 	"golang.org/x/xerrors"
 )
 
+// SonyBankStatementSchema is the schema of rows projected by SonyBankStatement.
+var SonyBankStatementSchema = bigquery.Schema{
+	{Name: "date", Type: bigquery.DateFieldType, Required: true, Description: "お取り引き日"},
+	{Name: "description", Type: bigquery.StringFieldType, Required: true, Description: "摘要"},
+	{Name: "reference", Type: bigquery.StringFieldType, Description: "参考情報"},
+	{Name: "deposit_amount", Type: bigquery.NumericFieldType, Description: "お預け入れ額"},
+	{Name: "withdrawal_amount", Type: bigquery.NumericFieldType, Description: "お引き出し額"},
+	{Name: "balance", Type: bigquery.NumericFieldType, Required: true, Description: "差し引き残高"},
+}
+
 // SonyBankStatement build a handler for statements of Sony Bank (ソニー銀行).
 func SonyBankStatement(name, pattern string, table Table, notifier bqloader.Notifier) *bqloader.Handler {
 	projector := func(_ context.Context, r []string) ([]string, error) {
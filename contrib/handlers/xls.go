@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/extrame/xls"
+	"gitlab.com/osaki-lab/iowrapper"
+	"go.nownabe.dev/bqloader"
+	"golang.org/x/xerrors"
+)
+
+var (
+	errXLSNoWorkbook = errors.New("no workbook found")
+	errXLSNoSheet    = errors.New("no sheet found")
+)
+
+// XLSOption configures XLSParser.
+type XLSOption func(*xlsConfig)
+
+type xlsConfig struct {
+	sheetIndex int
+	sheetName  string
+	firstCol   int
+	lastCol    int
+	filter     func([]string) bool
+	formatter  func(int, string) string
+}
+
+// XLSSheetIndex selects the sheet to parse by the 0-based index. Default is the first sheet.
+func XLSSheetIndex(i int) XLSOption {
+	return func(c *xlsConfig) {
+		c.sheetIndex = i
+		c.sheetName = ""
+	}
+}
+
+// XLSSheetName selects the sheet to parse by the name.
+func XLSSheetName(name string) XLSOption {
+	return func(c *xlsConfig) {
+		c.sheetName = name
+	}
+}
+
+// XLSColumns limits columns to parse from first to last (0-based, inclusive).
+// Records have the same number of columns as the range.
+// By default, records have columns from the first to the last cell of each row.
+func XLSColumns(first, last int) XLSOption {
+	return func(c *xlsConfig) {
+		c.firstCol = first
+		c.lastCol = last
+	}
+}
+
+// XLSRowFilter keeps only records for which f returns true.
+func XLSRowFilter(f func(record []string) bool) XLSOption {
+	return func(c *xlsConfig) {
+		c.filter = f
+	}
+}
+
+// XLSCellFormatter formats each cell with f given the column index in the record and the cell value.
+// Cells are formatted before XLSRowFilter.
+func XLSCellFormatter(f func(col int, value string) string) XLSOption {
+	return func(c *xlsConfig) {
+		c.formatter = f
+	}
+}
+
+// XLSParser builds a parser for legacy Excel workbooks (.xls).
+// Blank rows are skipped.
+func XLSParser(opts ...XLSOption) bqloader.Parser {
+	cfg := &xlsConfig{lastCol: -1}
+	for _, o := range opts {
+		o(cfg)
+	}
+
+	return func(_ context.Context, r io.Reader) ([][]string, error) {
+		if cfg.lastCol >= 0 && (cfg.firstCol < 0 || cfg.firstCol > cfg.lastCol) {
+			return nil, xerrors.Errorf("invalid column range: %d to %d", cfg.firstCol, cfg.lastCol)
+		}
+
+		wb, err := xls.OpenReader(iowrapper.NewSeeker(r), "utf-8")
+		if err != nil {
+			return nil, xerrors.Errorf("failed to open xls file: %w", err)
+		}
+		if wb == nil {
+			return nil, errXLSNoWorkbook
+		}
+
+		sheet, err := cfg.sheet(wb)
+		if err != nil {
+			return nil, err
+		}
+
+		records := [][]string{}
+
+		for i := 0; i <= int(sheet.MaxRow); i++ {
+			row, ok := getXLSRow(sheet, i)
+			if !ok {
+				continue
+			}
+
+			record := cfg.record(row)
+
+			if isBlankRecord(record) {
+				continue
+			}
+
+			if cfg.filter != nil && !cfg.filter(record) {
+				continue
+			}
+
+			records = append(records, record)
+		}
+
+		return records, nil
+	}
+}
+
+func (c *xlsConfig) sheet(wb *xls.WorkBook) (*xls.WorkSheet, error) {
+	if c.sheetName == "" {
+		// GetSheet doesn't check the bounds of the index.
+		if c.sheetIndex >= 0 && c.sheetIndex < wb.NumSheets() {
+			if sheet := wb.GetSheet(c.sheetIndex); sheet != nil {
+				return sheet, nil
+			}
+		}
+
+		return nil, xerrors.Errorf("%w: index %d", errXLSNoSheet, c.sheetIndex)
+	}
+
+	// GetSheet may return nil for sheets without cells such as chart sheets.
+	for i := 0; i < wb.NumSheets(); i++ {
+		if sheet := wb.GetSheet(i); sheet != nil && sheet.Name == c.sheetName {
+			return sheet, nil
+		}
+	}
+
+	return nil, xerrors.Errorf("%w: %s", errXLSNoSheet, c.sheetName)
+}
+
+func (c *xlsConfig) record(row *xls.Row) []string {
+	first, last := row.FirstCol(), row.LastCol()-1
+	if c.lastCol >= 0 {
+		first, last = c.firstCol, c.lastCol
+	}
+
+	record := make([]string, 0, last-first+1)
+
+	for col := first; col <= last; col++ {
+		v := row.Col(col)
+		if c.formatter != nil {
+			v = c.formatter(col-first, v)
+		}
+		record = append(record, v)
+	}
+
+	return record
+}
+
+// getXLSRow returns the row if it exists.
+// xls.WorkSheet.Row panics for missing rows.
+func getXLSRow(sheet *xls.WorkSheet, i int) (r *xls.Row, ok bool) {
+	defer func() {
+		if recover() != nil {
+			r, ok = nil, false
+		}
+	}()
+
+	return sheet.Row(i), true
+}
+
+func isBlankRecord(record []string) bool {
+	for _, v := range record {
+		if v != "" {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,111 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"go.nownabe.dev/bqloader/contrib/handlers"
+)
+
+func Test_XLSParser(t *testing.T) {
+	t.Parallel()
+
+	// multi_sheet.xls has two sheets, Summary and 明細.
+	// Rows of 明細 start at the column B and the row 6 is missing.
+	wb, err := os.ReadFile("testdata/multi_sheet.xls")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		opts   []handlers.XLSOption
+		expect [][]string
+		err    string
+	}{
+		"first sheet": {
+			opts:   nil,
+			expect: [][]string{{"Summary"}, {"total", "1560"}},
+		},
+		"sheet index": {
+			opts: []handlers.XLSOption{handlers.XLSSheetIndex(1)},
+			expect: [][]string{
+				{"ご利用明細"},
+				{"ご利用日", "ご利用内容", "金額", "備考"},
+				{"2022/07/01", "コンビニ", "1000", ""},
+				{"2022/07/03", "書店", "560.5", "ポイント"},
+				{"2022/07/05", "返品", "-500"},
+				{"合計", "", "1060.5"},
+			},
+		},
+		"sheet name, columns and filter": {
+			opts: []handlers.XLSOption{
+				handlers.XLSSheetName("明細"),
+				handlers.XLSColumns(0, 3),
+				handlers.XLSRowFilter(func(r []string) bool { return strings.HasPrefix(r[1], "2022/") }),
+			},
+			expect: [][]string{
+				{"", "2022/07/01", "コンビニ", "1000"},
+				{"", "2022/07/03", "書店", "560.5"},
+				{"", "2022/07/05", "返品", "-500"},
+			},
+		},
+		"cell formatter": {
+			opts: []handlers.XLSOption{
+				handlers.XLSSheetName("明細"),
+				handlers.XLSColumns(1, 2),
+				handlers.XLSCellFormatter(func(col int, v string) string {
+					if col == 0 {
+						return strings.ReplaceAll(v, "/", "-")
+					}
+					return v
+				}),
+				handlers.XLSRowFilter(func(r []string) bool { return r[0] == "2022-07-05" }),
+			},
+			expect: [][]string{{"2022-07-05", "返品"}},
+		},
+		"unknown sheet name": {
+			opts: []handlers.XLSOption{handlers.XLSSheetName("Unknown")},
+			err:  "no sheet found: Unknown",
+		},
+		"sheet index out of range": {
+			opts: []handlers.XLSOption{handlers.XLSSheetIndex(2)},
+			err:  "no sheet found: index 2",
+		},
+		"negative sheet index": {
+			opts: []handlers.XLSOption{handlers.XLSSheetIndex(-1)},
+			err:  "no sheet found: index -1",
+		},
+		"sheet index far out of range": {
+			opts: []handlers.XLSOption{handlers.XLSSheetIndex(100)},
+			err:  "no sheet found: index 100",
+		},
+		"invalid columns": {
+			opts: []handlers.XLSOption{handlers.XLSColumns(3, 1)},
+			err:  "invalid column range",
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			records, err := handlers.XLSParser(c.opts...)(context.Background(), bytes.NewReader(wb))
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Errorf("error should contain %q, but %v", c.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			assertEqual(t, c.expect, records)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"go.nownabe.dev/bqloader"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/xerrors"
+)
+
+var (
+	errZenginNoHeader      = errors.New("data record without header record")
+	errZenginNoTrailer     = errors.New("header record without trailer record")
+	errZenginTotalMismatch = errors.New("trailer totals don't match data records")
+	errZenginType          = errors.New("unsupported type code in header record")
+	errZenginDate          = errors.New("invalid transfer date in header record")
+)
+
+const zenginRecordLength = 120
+
+// ZenginTransferSchema is the schema of rows projected by ZenginTransfer.
+var ZenginTransferSchema = bigquery.Schema{
+	{Name: "bank_code", Type: bigquery.StringFieldType, Required: true, Description: "被仕向銀行番号"},
+	{Name: "bank_name", Type: bigquery.StringFieldType, Description: "被仕向銀行名"},
+	{Name: "branch_code", Type: bigquery.StringFieldType, Required: true, Description: "被仕向支店番号"},
+	{Name: "branch_name", Type: bigquery.StringFieldType, Description: "被仕向支店名"},
+	{Name: "account_type", Type: bigquery.StringFieldType, Required: true, Description: "預金種目"},
+	{Name: "account_number", Type: bigquery.StringFieldType, Required: true, Description: "口座番号"},
+	{Name: "recipient_name", Type: bigquery.StringFieldType, Required: true, Description: "受取人名"},
+	{Name: "amount", Type: bigquery.IntegerFieldType, Required: true, Description: "振込金額"},
+	{Name: "customer_code1", Type: bigquery.StringFieldType, Description: "顧客コード1"},
+	{Name: "customer_code2", Type: bigquery.StringFieldType, Description: "顧客コード2"},
+	{Name: "result_code", Type: bigquery.StringFieldType, Description: "振込結果コード"},
+}
+
+// zenginLayout is the layout of Zengin (全銀協) transfer files of 120 bytes records.
+// Data records are parsed into the columns of ZenginTransferSchema following the record type.
+var zenginLayout = bqloader.FixedWidthLayout{
+	Encoding:     japanese.ShiftJIS,
+	RecordLength: zenginRecordLength,
+	Records: []bqloader.FixedWidthRecord{
+		// Header record with the type code (種別コード) and the transfer date (振込指定日) in MMDD.
+		{Code: "1", Columns: []bqloader.FixedWidthColumn{
+			{Start: 0, Width: 1},
+			{Start: 1, Width: 2, Trim: bqloader.TrimNone},
+			{Start: 54, Width: 4, Trim: bqloader.TrimNone},
+		}},
+		// Data record.
+		{Code: "2", Columns: []bqloader.FixedWidthColumn{
+			{Start: 0, Width: 1},
+			{Start: 1, Width: 4},
+			{Start: 5, Width: 15},
+			{Start: 20, Width: 3},
+			{Start: 23, Width: 15},
+			{Start: 42, Width: 1},
+			{Start: 43, Width: 7},
+			{Start: 50, Width: 30},
+			{Start: 80, Width: 10, Trim: bqloader.TrimLeadingZeros},
+			{Start: 91, Width: 10},
+			{Start: 101, Width: 10},
+			// The data record of 総合振込 and 給与振込 ends with a dummy area (ダミー) up to offset 119.
+			// Banks set the result code (振込結果コード) at its last byte in result files (振込結果データ),
+			// and it's blank in request files.
+			{Start: 119, Width: 1},
+		}},
+		// Trailer record with the count and the total amount of data records.
+		{Code: "8", Columns: []bqloader.FixedWidthColumn{
+			{Start: 0, Width: 1},
+			{Start: 1, Width: 6, Trim: bqloader.TrimLeadingZeros},
+			{Start: 7, Width: 12, Trim: bqloader.TrimLeadingZeros},
+		}},
+		// End record.
+		{Code: "9", Skip: true},
+	},
+}
+
+// zenginTypes are type codes (種別コード) of transfers in header records.
+var zenginTypes = map[string]string{
+	"11": "給与振込",
+	"12": "賞与振込",
+	"21": "総合振込",
+}
+
+// ZenginParser builds a stream parser for Zengin (全銀協) transfer files such as bulk and payroll transfers
+// and their results. It parses data records into the columns of ZenginTransferSchema.
+// Header records must have a type code of 総合振込 (21), 給与振込 (11) or 賞与振込 (12) and a transfer date,
+// and the count and the total amount in each trailer record are validated against data records
+// when the trailer record is read.
+func ZenginParser() bqloader.StreamParser {
+	parser := bqloader.FixedWidthParser(zenginLayout)
+
+	return func(ctx context.Context, r io.Reader) (bqloader.RowIterator, error) {
+		raws, err := parser(ctx, r)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to parse as Zengin format: %w", err)
+		}
+
+		return &zenginIterator{raws: raws}, nil
+	}
+}
+
+type zenginIterator struct {
+	raws    bqloader.RowIterator
+	inGroup bool
+	count   int64
+	total   int64
+}
+
+func (it *zenginIterator) Next() ([]string, error) {
+	for {
+		raw, err := it.raws.Next()
+		if errors.Is(err, io.EOF) {
+			if it.inGroup {
+				return nil, errZenginNoTrailer
+			}
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("failed to parse as Zengin format: %w", err)
+		}
+
+		switch raw[0] {
+		case "1":
+			if it.inGroup {
+				return nil, errZenginNoTrailer
+			}
+			if err := validateZenginHeader(raw); err != nil {
+				return nil, err
+			}
+			it.inGroup = true
+			it.count, it.total = 0, 0
+		case "2":
+			if !it.inGroup {
+				return nil, errZenginNoHeader
+			}
+
+			amount, err := strconv.ParseInt(raw[8], 10, 64)
+			if err != nil {
+				return nil, xerrors.Errorf("failed to parse amount of data record %d: %w", it.count+1, err)
+			}
+
+			it.count++
+			it.total += amount
+
+			return raw[1:], nil
+		case "8":
+			if !it.inGroup {
+				return nil, errZenginNoHeader
+			}
+
+			if raw[1] != strconv.FormatInt(it.count, 10) || raw[2] != strconv.FormatInt(it.total, 10) {
+				return nil, xerrors.Errorf("%w: %s records of %s in trailer, but %d records of %d",
+					errZenginTotalMismatch, raw[1], raw[2], it.count, it.total)
+			}
+			it.inGroup = false
+		}
+	}
+}
+
+// validateZenginHeader validates the type code and the transfer date in MMDD of a header record.
+func validateZenginHeader(raw []string) error {
+	if _, ok := zenginTypes[raw[1]]; !ok {
+		return xerrors.Errorf("%w: %q", errZenginType, raw[1])
+	}
+
+	// The year is a leap year to accept February 29.
+	if _, err := time.Parse("20060102", "2000"+raw[2]); err != nil {
+		return xerrors.Errorf("%w: %q", errZenginDate, raw[2])
+	}
+
+	return nil
+}
+
+// ZenginTransfer builds a handler for Zengin (全銀協) transfer files such as bulk and payroll transfers and their results.
+func ZenginTransfer(name, pattern string, table Table, notifier bqloader.Notifier) *bqloader.Handler {
+	projector := func(_ context.Context, r []string) ([]string, error) {
+		return r, nil
+	}
+
+	return &bqloader.Handler{
+		Name:            name,
+		Pattern:         regexp.MustCompile(pattern),
+		SkipLeadingRows: 0,
+
+		StreamParser: ZenginParser(),
+		Projector:    projector,
+		Notifier:     notifier,
+
+		Project: table.Project,
+		Dataset: table.Dataset,
+		Table:   table.Table,
+	}
+}
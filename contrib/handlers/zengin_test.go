@@ -0,0 +1,186 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"go.nownabe.dev/bqloader"
+	"go.nownabe.dev/bqloader/contrib/handlers"
+)
+
+func Test_ZenginTransfer(t *testing.T) {
+	t.Parallel()
+
+	const txt = "testdata/zengin_transfer.txt"
+
+	expected := [][]string{
+		{"0001", "ﾐｽﾞﾎ", "001", "ﾄｳｷﾖｳ", "1", "0123456", "ﾔﾏﾀﾞ ﾀﾛｳ", "250000", "EMP001", "", "0"},
+		{"0005", "ﾐﾂﾋﾞｼﾕ-ｴﾌｼﾞｴｲ", "123", "ｼﾌﾞﾔ", "2", "7654321", "ｽｽﾞｷ ﾊﾅｺ", "180500", "EMP002", "", "4"},
+		{"0033", "PAYPAY", "002", "ﾎﾝﾃﾝ", "1", "1111111", "ｻﾄｳ ｼﾞﾛｳ", "99", "", "", ""},
+	}
+
+	h, tl := buildTestHandler(t, txt, handlers.ZenginTransfer)
+
+	name := "path_to/zengin_transfer.txt"
+	e := bqloader.Event{Name: name, Bucket: "bucket"}
+
+	if err := h.Handle(context.Background(), e); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	assertEqual(t, expected, tl.result)
+}
+
+func Test_ZenginParser(t *testing.T) {
+	t.Parallel()
+
+	body, err := os.ReadFile("testdata/zengin_transfer.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const recordLength = 122 // 120 bytes and CRLF.
+
+	header := body[:recordLength]
+	data := body[recordLength : recordLength*4]
+	trailer := body[recordLength*4 : recordLength*5]
+	end := body[recordLength*5:]
+
+	join := func(records ...[]byte) []byte {
+		return bytes.Join(records, nil)
+	}
+
+	cases := map[string]struct {
+		body []byte
+		rows int
+		err  string
+	}{
+		"without line breaks": {
+			body: bytes.ReplaceAll(body, []byte("\r\n"), nil),
+			rows: 3,
+		},
+		"multiple groups": {
+			body: join(header, data, trailer, header, data, trailer, end),
+			rows: 6,
+		},
+		"count mismatch": {
+			body: join(header, data[:recordLength*2], trailer, end),
+			err:  "trailer totals don't match data records: 3 records of 430599 in trailer, but 2 records of 430500",
+		},
+		"total mismatch": {
+			body: join(header, data, bytes.Replace(trailer, []byte("430599"), []byte("430600"), 1), end),
+			err:  "trailer totals don't match data records",
+		},
+		"no trailer": {
+			body: join(header, data, end),
+			err:  "header record without trailer record",
+		},
+		"no header": {
+			body: join(data, trailer, end),
+			err:  "data record without header record",
+		},
+		"invalid amount": {
+			body: join(header, bytes.Replace(data, []byte("0000000099"), []byte("00000000AB"), 1), trailer, end),
+			err:  "failed to parse amount of data record 3",
+		},
+		"unknown record type": {
+			body: join(header, data, []byte(strings.Repeat("3", 120)), trailer, end),
+			err:  "unknown record type",
+		},
+		"bonus transfer": {
+			body: join(replaceAt(header, 1, "12"), data, trailer, end),
+			rows: 3,
+		},
+		"unsupported type code": {
+			body: join(replaceAt(header, 1, "91"), data, trailer, end),
+			err:  `unsupported type code in header record: "91"`,
+		},
+		"invalid transfer date": {
+			body: join(replaceAt(header, 54, "1332"), data, trailer, end),
+			err:  `invalid transfer date in header record: "1332"`,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			records, err := readAllRows(handlers.ZenginParser(), c.body)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Errorf("error should contain %q, but %v", c.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if len(records) != c.rows {
+				t.Errorf("expected %d records, but %d", c.rows, len(records))
+			}
+		})
+	}
+}
+
+func Test_ZenginParser_Stream(t *testing.T) {
+	t.Parallel()
+
+	body, err := os.ReadFile("testdata/zengin_transfer.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The trailer totals 430599 yen of 3 records.
+	body = bytes.Replace(body, []byte("430599"), []byte("430600"), 1)
+
+	it, err := handlers.ZenginParser()(context.Background(), bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Data records are read before the trailer record fails.
+	for i := 0; i < 3; i++ {
+		if _, err := it.Next(); err != nil {
+			t.Fatalf("Unexpected error at record %d: %v", i, err)
+		}
+	}
+
+	if _, err := it.Next(); err == nil || !strings.Contains(err.Error(), "trailer totals don't match data records") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// replaceAt replaces bytes of a record at the offset.
+func replaceAt(record []byte, offset int, s string) []byte {
+	r := append([]byte{}, record...)
+	copy(r[offset:], s)
+
+	return r
+}
+
+func readAllRows(parser bqloader.StreamParser, body []byte) ([][]string, error) {
+	it, err := parser(context.Background(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	records := [][]string{}
+	for {
+		r, err := it.Next()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+}
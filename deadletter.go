@@ -0,0 +1,152 @@
+package bqloader
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"sync"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/storage"
+	"golang.org/x/xerrors"
+)
+
+// DeadLetterSink receives rows rejected by ErrorPolicy.
+// Rejected rows are sent once for each event after it's processed, even if it fails with too many bad rows.
+type DeadLetterSink interface {
+	Send(context.Context, []*RejectedRow) error
+}
+
+// JSONLDeadLetterSink writes rejected rows into an io.Writer as JSON Lines.
+type JSONLDeadLetterSink struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// NewJSONLDeadLetterSink builds a DeadLetterSink writing JSON Lines into w.
+func NewJSONLDeadLetterSink(w io.Writer) *JSONLDeadLetterSink {
+	return &JSONLDeadLetterSink{w: w}
+}
+
+// Send writes rejected rows as JSON Lines.
+func (s *JSONLDeadLetterSink) Send(_ context.Context, rows []*RejectedRow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return writeJSONL(s.w, rows)
+}
+
+// FileDeadLetterSink appends rejected rows into a local file as JSON Lines.
+type FileDeadLetterSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileDeadLetterSink builds a DeadLetterSink appending JSON Lines into the file at path.
+func NewFileDeadLetterSink(path string) *FileDeadLetterSink {
+	return &FileDeadLetterSink{path: path}
+}
+
+// Send appends rejected rows into the file.
+func (s *FileDeadLetterSink) Send(_ context.Context, rows []*RejectedRow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return xerrors.Errorf("failed to open %s: %w", s.path, err)
+	}
+
+	if err := writeJSONL(f, rows); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return xerrors.Errorf("failed to close %s: %w", s.path, err)
+	}
+
+	return nil
+}
+
+// GCSDeadLetterSink writes rejected rows into Cloud Storage objects as JSON Lines.
+// An object is written for each source object at <prefix>/<source object name>.jsonl.
+// Don't write into a path matched to handlers' patterns.
+type GCSDeadLetterSink struct {
+	bucket string
+	prefix string
+	client *storage.Client
+}
+
+// NewGCSDeadLetterSink builds a DeadLetterSink writing objects into the bucket under the prefix.
+func NewGCSDeadLetterSink(ctx context.Context, bucket, prefix string) (*GCSDeadLetterSink, error) {
+	c, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to build storage client: %w", err)
+	}
+
+	return &GCSDeadLetterSink{bucket: bucket, prefix: prefix, client: c}, nil
+}
+
+// Send writes rejected rows into an object.
+func (s *GCSDeadLetterSink) Send(ctx context.Context, rows []*RejectedRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	name := path.Join(s.prefix, rows[0].Name) + ".jsonl"
+
+	w := s.client.Bucket(s.bucket).Object(name).NewWriter(ctx)
+	w.ContentType = "application/x-ndjson"
+
+	if err := writeJSONL(w, rows); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return xerrors.Errorf("failed to write gs://%s/%s: %w", s.bucket, name, err)
+	}
+
+	return nil
+}
+
+// BigQueryDeadLetterSink inserts rejected rows into a BigQuery table.
+// The table must have the schema inferred from RejectedRow.
+type BigQueryDeadLetterSink struct {
+	table *bigquery.Table
+}
+
+// NewBigQueryDeadLetterSink builds a DeadLetterSink inserting rows into the table.
+func NewBigQueryDeadLetterSink(ctx context.Context, project, dataset, table string) (*BigQueryDeadLetterSink, error) {
+	bq, err := bigquery.NewClient(ctx, project)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to build bigquery client for %s.%s.%s: %w",
+			project, dataset, table, err)
+	}
+
+	return &BigQueryDeadLetterSink{table: bq.Dataset(dataset).Table(table)}, nil
+}
+
+// Send inserts rejected rows into the table.
+func (s *BigQueryDeadLetterSink) Send(ctx context.Context, rows []*RejectedRow) error {
+	if err := s.table.Inserter().Put(ctx, rows); err != nil {
+		return xerrors.Errorf("failed to insert rejected rows: %w", err)
+	}
+
+	return nil
+}
+
+func writeJSONL(w io.Writer, rows []*RejectedRow) error {
+	enc := json.NewEncoder(w)
+
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			return xerrors.Errorf("failed to write rejected row %d: %w", r.Row, err)
+		}
+	}
+
+	return nil
+}
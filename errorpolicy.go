@@ -0,0 +1,129 @@
+package bqloader
+
+import (
+	"sort"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// ErrorPolicy defines how to handle rows which projectors failed to project.
+// Use FailFast, SkipBadRows or SkipBadRowsRatio to build ErrorPolicy.
+type ErrorPolicy struct {
+	// maxBadRows is the maximum number of rejected rows. Negative means unlimited.
+	maxBadRows int
+
+	// maxBadRatio is the maximum ratio of rejected rows to all rows. Negative means unlimited.
+	maxBadRatio float64
+}
+
+// FailFast fails the whole file at the first bad row. This is the default policy.
+func FailFast() *ErrorPolicy {
+	return &ErrorPolicy{maxBadRows: 0, maxBadRatio: -1}
+}
+
+// SkipBadRows skips bad rows up to n rows.
+// The whole file fails if more than n rows are rejected.
+func SkipBadRows(n int) *ErrorPolicy {
+	return &ErrorPolicy{maxBadRows: n, maxBadRatio: -1}
+}
+
+// SkipBadRowsRatio skips bad rows as long as the ratio of rejected rows to all rows is less than or equal to ratio.
+// ratio is a value between 0 and 1, e.g. 0.01 means 1%.
+func SkipBadRowsRatio(ratio float64) *ErrorPolicy {
+	return &ErrorPolicy{maxBadRows: -1, maxBadRatio: ratio}
+}
+
+// RejectedRow is a row rejected by ErrorPolicy.
+type RejectedRow struct {
+	// Handler is the name of the handler which rejected this row.
+	Handler string `json:"handler" bigquery:"handler"`
+
+	// Bucket and Name identify the source object.
+	Bucket string `json:"bucket" bigquery:"bucket"`
+	Name   string `json:"name" bigquery:"name"`
+
+	// Row is the row number in the source excluding skipped leading rows.
+	Row int `json:"row" bigquery:"row"`
+
+	// Line is the line number in the source, the same as in error messages.
+	Line int `json:"line" bigquery:"line"`
+
+	// Columns is the raw source record.
+	Columns []string `json:"columns" bigquery:"columns"`
+
+	// Error is the error message returned by the projector.
+	Error string `json:"error" bigquery:"error"`
+}
+
+// rejector collects rejected rows in an event according to ErrorPolicy.
+type rejector struct {
+	policy  *ErrorPolicy
+	handler string
+	event   Event
+
+	mu   sync.Mutex
+	rows []*RejectedRow
+}
+
+func newRejector(h *Handler, e Event) *rejector {
+	p := h.ErrorPolicy
+	if p == nil {
+		p = FailFast()
+	}
+
+	return &rejector{policy: p, handler: h.Name, event: e}
+}
+
+// reject records a bad row and returns an error if the number of rejected rows exceeds the limit.
+func (r *rejector) reject(row int, line uint, columns []string, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.policy.maxBadRows == 0 {
+		return err
+	}
+
+	r.rows = append(r.rows, &RejectedRow{
+		Handler: r.handler,
+		Bucket:  r.event.Bucket,
+		Name:    r.event.Name,
+		Row:     row,
+		Line:    int(line),
+		Columns: columns,
+		Error:   err.Error(),
+	})
+
+	if r.policy.maxBadRows > 0 && len(r.rows) > r.policy.maxBadRows {
+		return xerrors.Errorf("too many bad rows: more than %d rows are rejected: %w", r.policy.maxBadRows, err)
+	}
+
+	return nil
+}
+
+// check returns an error if the ratio of rejected rows exceeds the limit.
+func (r *rejector) check(total int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.policy.maxBadRatio < 0 || total == 0 {
+		return nil
+	}
+
+	if ratio := float64(len(r.rows)) / float64(total); ratio > r.policy.maxBadRatio {
+		return xerrors.Errorf("too many bad rows: %d of %d rows are rejected (%.2f%% > %.2f%%): first error: %s",
+			len(r.rows), total, ratio*100, r.policy.maxBadRatio*100, r.rows[0].Error)
+	}
+
+	return nil
+}
+
+// rejected returns rejected rows in the order of the source.
+func (r *rejector) rejected() []*RejectedRow {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sort.Slice(r.rows, func(i, j int) bool { return r.rows[i].Row < r.rows[j].Row })
+
+	return r.rows
+}
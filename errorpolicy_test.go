@@ -0,0 +1,248 @@
+package bqloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+func Test_Handler_ErrorPolicy(t *testing.T) {
+	t.Parallel()
+
+	projector := func(_ context.Context, r []string) ([]string, error) {
+		if r[1] == "bad" {
+			return nil, fmt.Errorf("bad value")
+		}
+
+		return r, nil
+	}
+
+	const rawCSV = "header,value\n1,good\n2,bad\n3,good\n4,bad\n5,good\n"
+
+	cases := map[string]struct {
+		policy           *ErrorPolicy
+		expectedHasError bool
+		expectedRejected int
+	}{
+		"default":                {policy: nil, expectedHasError: true, expectedRejected: 0},
+		"fail fast":              {policy: FailFast(), expectedHasError: true, expectedRejected: 0},
+		"skip 2 rows":            {policy: SkipBadRows(2), expectedHasError: false, expectedRejected: 2},
+		"skip 1 row":             {policy: SkipBadRows(1), expectedHasError: true, expectedRejected: 2},
+		"skip 40 percent":        {policy: SkipBadRowsRatio(0.4), expectedHasError: false, expectedRejected: 2},
+		"skip 30 percent":        {policy: SkipBadRowsRatio(0.3), expectedHasError: true, expectedRejected: 2},
+		"skip unlimited percent": {policy: SkipBadRowsRatio(1), expectedHasError: false, expectedRejected: 2},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tl := &testStreamLoader{}
+			buf := &bytes.Buffer{}
+
+			handler := &Handler{
+				Name:            "test-handler",
+				Parser:          CSVParser(),
+				Projector:       projector,
+				SkipLeadingRows: 1,
+				ErrorPolicy:     c.policy,
+				DeadLetterSink:  NewJSONLDeadLetterSink(buf),
+				BatchSize:       2,
+				Extractor:       newTestExtractor(),
+				Loader:          tl,
+				semaphore:       make(chan struct{}, 2),
+			}
+			e := Event{Name: "test/name", Bucket: "bucket", source: bytes.NewBufferString(rawCSV)}
+
+			err := handler.Handle(context.Background(), e)
+			if c.expectedHasError {
+				if err == nil {
+					t.Fatalf("expected error but no error occurred")
+				}
+				if tl.closed {
+					t.Errorf("records channel should not be closed")
+				}
+				if n := strings.Count(buf.String(), "\n"); n != c.expectedRejected {
+					t.Errorf("%d rejected rows should be sent, but %d", c.expectedRejected, n)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if len(tl.result) != 3 {
+				t.Errorf("Size of result records should be 3, but %d", len(tl.result))
+			}
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != c.expectedRejected {
+				t.Fatalf("Size of rejected rows should be %d, but %d", c.expectedRejected, len(lines))
+			}
+
+			var row RejectedRow
+			if err := json.Unmarshal([]byte(lines[0]), &row); err != nil {
+				t.Fatalf("failed to unmarshal rejected row: %v", err)
+			}
+
+			if row.Row != 1 || row.Line != 2 || row.Columns[0] != "2" || row.Name != "test/name" || row.Handler != "test-handler" {
+				t.Errorf("unexpected rejected row: %+v", row)
+			}
+
+			if !strings.Contains(row.Error, "bad value") {
+				t.Errorf("error of rejected row should contain the projector error, but %q", row.Error)
+			}
+		})
+	}
+}
+
+func Test_FileDeadLetterSink(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "rejected.jsonl")
+	s := NewFileDeadLetterSink(path)
+
+	for i := 0; i < 2; i++ {
+		rows := []*RejectedRow{{Row: i, Columns: []string{"a"}, Error: "error"}}
+		if err := s.Send(context.Background(), rows); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(string(body), "\n"); n != 2 {
+		t.Errorf("file should have 2 lines, but %d", n)
+	}
+}
+
+func Test_GCSDeadLetterSink(t *testing.T) {
+	t.Parallel()
+
+	var name, body string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Multipart upload of the object metadata and the media.
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		var md struct{ Name string }
+		for i := 0; i < 2; i++ {
+			p, err := mr.NextPart()
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if i == 0 {
+				_ = json.NewDecoder(p).Decode(&md)
+			} else {
+				b, _ := io.ReadAll(p)
+				body = string(b)
+			}
+		}
+		name = md.Name
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"bucket": "b", "name": md.Name})
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := storage.NewClient(context.Background(),
+		option.WithEndpoint(srv.URL+"/storage/v1/"), option.WithoutAuthentication(), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &GCSDeadLetterSink{bucket: "b", prefix: "rejected", client: c}
+	rows := []*RejectedRow{
+		{Handler: "h", Bucket: "src", Name: "dir/a.csv", Row: 1, Line: 2, Columns: []string{"2", "bad"}, Error: "bad value"},
+		{Handler: "h", Bucket: "src", Name: "dir/a.csv", Row: 3, Line: 4, Columns: []string{"4", "bad"}, Error: "bad value"},
+	}
+
+	if err := s.Send(context.Background(), rows); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if name != "rejected/dir/a.csv.jsonl" {
+		t.Errorf("object should be written at rejected/dir/a.csv.jsonl, but %s", name)
+	}
+
+	expected := `{"handler":"h","bucket":"src","name":"dir/a.csv","row":1,"line":2,"columns":["2","bad"],"error":"bad value"}` + "\n" +
+		`{"handler":"h","bucket":"src","name":"dir/a.csv","row":3,"line":4,"columns":["4","bad"],"error":"bad value"}` + "\n"
+	if body != expected {
+		t.Errorf("object should be\n%s\nbut\n%s", expected, body)
+	}
+}
+
+func Test_BigQueryDeadLetterSink(t *testing.T) {
+	t.Parallel()
+
+	var req struct {
+		Rows []struct {
+			JSON map[string]interface{} `json:"json"`
+		} `json:"rows"`
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/projects/p/datasets/d/tables/t/insertAll") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := bigquery.NewClient(context.Background(), "p",
+		option.WithEndpoint(srv.URL+"/"), option.WithoutAuthentication(), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &BigQueryDeadLetterSink{table: c.Dataset("d").Table("t")}
+	rows := []*RejectedRow{
+		{Handler: "h", Bucket: "src", Name: "a.csv", Row: 1, Line: 2, Columns: []string{"2", "bad"}, Error: "bad value"},
+	}
+
+	if err := s.Send(context.Background(), rows); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(req.Rows) != 1 {
+		t.Fatalf("1 row should be inserted, but %d", len(req.Rows))
+	}
+
+	actual, _ := json.Marshal(req.Rows[0].JSON)
+	expected := `{"bucket":"src","columns":["2","bad"],"error":"bad value","handler":"h","line":2,"name":"a.csv","row":1}`
+	if string(actual) != expected {
+		t.Errorf("inserted row should be %s, but %s", expected, actual)
+	}
+}
@@ -1,23 +1,103 @@
 package bqloader
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"strconv"
 	"time"
 
 	"github.com/rs/zerolog"
+	"golang.org/x/xerrors"
 )
 
 // Event is an event from Cloud Storage.
+// Fields are decoded from the object payload of Cloud Storage events.
+// Generation, Metageneration and Size are accepted both as JSON strings and numbers.
 type Event struct {
 	Name        string    `json:"name"`
 	Bucket      string    `json:"bucket"`
 	TimeCreated time.Time `json:"timeCreated"`
 
+	// Generation is the generation of the object. Zero if unknown.
+	Generation int64 `json:"generation,string"`
+
+	Kind                    string    `json:"kind"`
+	ID                      string    `json:"id"`
+	SelfLink                string    `json:"selfLink"`
+	MediaLink               string    `json:"mediaLink"`
+	Metageneration          int64     `json:"metageneration,string"`
+	ContentType             string    `json:"contentType"`
+	ContentEncoding         string    `json:"contentEncoding"`
+	ContentDisposition      string    `json:"contentDisposition"`
+	ContentLanguage         string    `json:"contentLanguage"`
+	CacheControl            string    `json:"cacheControl"`
+	StorageClass            string    `json:"storageClass"`
+	Size                    int64     `json:"size,string"`
+	MD5Hash                 string    `json:"md5Hash"`
+	CRC32C                  string    `json:"crc32c"`
+	ETag                    string    `json:"etag"`
+	Updated                 time.Time `json:"updated"`
+	TimeStorageClassUpdated time.Time `json:"timeStorageClassUpdated"`
+
+	// Metadata is the custom metadata set by the uploader.
+	Metadata map[string]string `json:"metadata"`
+
 	// for test
 	source io.Reader
 }
 
+// UnmarshalJSON decodes integers in either strings as in the object payload or numbers.
+func (e *Event) UnmarshalJSON(b []byte) error {
+	type event Event
+
+	v := struct {
+		*event
+		Generation     jsonInt64 `json:"generation"`
+		Metageneration jsonInt64 `json:"metageneration"`
+		Size           jsonInt64 `json:"size"`
+	}{event: (*event)(e)}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	e.Generation = int64(v.Generation)
+	e.Metageneration = int64(v.Metageneration)
+	e.Size = int64(v.Size)
+
+	return nil
+}
+
+// jsonInt64 is an int64 in either a JSON string or a JSON number.
+type jsonInt64 int64
+
+func (i *jsonInt64) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+
+	if len(s) >= 2 && s[0] == '"' {
+		var err error
+		if s, err = strconv.Unquote(s); err != nil {
+			return err
+		}
+		if s == "" {
+			return nil
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return xerrors.Errorf("invalid integer %s: %w", b, err)
+	}
+
+	*i = jsonInt64(n)
+
+	return nil
+}
+
 // FullPath returns full path of storage object beginning with gs://.
 func (e *Event) FullPath() string {
 	return fmt.Sprintf("gs://%s/%s", e.Bucket, e.Name)
@@ -27,7 +107,19 @@ func (e *Event) logger(l *zerolog.Logger) *zerolog.Logger {
 	d := zerolog.Dict().
 		Str("name", e.Name).
 		Str("bucket", e.Bucket).
-		Time("timeCreated", e.TimeCreated)
+		Time("timeCreated", e.TimeCreated).
+		Int64("generation", e.Generation).
+		Str("contentType", e.ContentType).
+		Int64("size", e.Size).
+		Str("md5Hash", e.MD5Hash)
+
+	if len(e.Metadata) > 0 {
+		m := zerolog.Dict()
+		for k, v := range e.Metadata {
+			m = m.Str(k, v)
+		}
+		d = d.Dict("metadata", m)
+	}
 
 	logger := l.With().Dict("event", d).Logger()
 	return &logger
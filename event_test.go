@@ -0,0 +1,109 @@
+package bqloader
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+const storageObjectPayload = `{
+  "kind": "storage#object",
+  "id": "bucket/statements/2022-07.csv/1656658000000000",
+  "selfLink": "https://www.googleapis.com/storage/v1/b/bucket/o/statements%2F2022-07.csv",
+  "name": "statements/2022-07.csv",
+  "bucket": "bucket",
+  "generation": "1656658000000000",
+  "metageneration": "1",
+  "contentType": "text/csv",
+  "timeCreated": "2022-07-01T06:46:40.000Z",
+  "updated": "2022-07-01T06:46:40.000Z",
+  "storageClass": "STANDARD",
+  "timeStorageClassUpdated": "2022-07-01T06:46:40.000Z",
+  "size": "1024",
+  "md5Hash": "XUFAKrxLKna5cZ2REBfFkg==",
+  "mediaLink": "https://storage.googleapis.com/download/storage/v1/b/bucket/o/statements%2F2022-07.csv?generation=1656658000000000&alt=media",
+  "metadata": {"account": "main"},
+  "crc32c": "yZRlqg==",
+  "etag": "CICy5eGV0PgCEAE="
+}`
+
+func TestEvent_Unmarshal(t *testing.T) {
+	t.Parallel()
+
+	var e Event
+	if err := json.Unmarshal([]byte(storageObjectPayload), &e); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if e.Name != "statements/2022-07.csv" || e.Bucket != "bucket" {
+		t.Errorf("unexpected object: %s", e.FullPath())
+	}
+
+	if e.Generation != 1656658000000000 || e.Metageneration != 1 {
+		t.Errorf("unexpected generation: %d, %d", e.Generation, e.Metageneration)
+	}
+
+	if e.ContentType != "text/csv" || e.Size != 1024 || e.MD5Hash != "XUFAKrxLKna5cZ2REBfFkg==" {
+		t.Errorf("unexpected content: %s, %d, %s", e.ContentType, e.Size, e.MD5Hash)
+	}
+
+	if e.Metadata["account"] != "main" {
+		t.Errorf("unexpected metadata: %v", e.Metadata)
+	}
+
+	if !e.TimeCreated.Equal(time.Date(2022, 7, 1, 6, 46, 40, 0, time.UTC)) {
+		t.Errorf("unexpected timeCreated: %s", e.TimeCreated)
+	}
+}
+
+func TestEvent_Unmarshal_Numbers(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		payload  string
+		expected Event
+		err      bool
+	}{
+		"numbers": {
+			payload:  `{"name": "a.csv", "generation": 1656658000000000, "metageneration": 2, "size": 1024}`,
+			expected: Event{Name: "a.csv", Generation: 1656658000000000, Metageneration: 2, Size: 1024},
+		},
+		"strings": {
+			payload:  `{"name": "a.csv", "generation": "1656658000000000", "metageneration": "2", "size": "1024"}`,
+			expected: Event{Name: "a.csv", Generation: 1656658000000000, Metageneration: 2, Size: 1024},
+		},
+		"missing": {
+			payload:  `{"name": "a.csv", "generation": null, "size": ""}`,
+			expected: Event{Name: "a.csv"},
+		},
+		"invalid": {
+			payload: `{"name": "a.csv", "generation": "1.5"}`,
+			err:     true,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var e Event
+			err := json.Unmarshal([]byte(c.payload), &e)
+			if c.err {
+				if err == nil {
+					t.Errorf("expected error but no error occurred")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if e.Name != c.expected.Name || e.Generation != c.expected.Generation ||
+				e.Metageneration != c.expected.Metageneration || e.Size != c.expected.Size {
+				t.Errorf("expected %+v, but %+v", c.expected, e)
+			}
+		})
+	}
+}
@@ -3,6 +3,9 @@ package bqloader
 import (
 	"context"
 	"io"
+	"os"
+	"path"
+	"path/filepath"
 
 	"cloud.google.com/go/storage"
 	"golang.org/x/xerrors"
@@ -28,6 +31,12 @@ func newDefaultExtractor(ctx context.Context, project string) (Extractor, error)
 
 func (e *defaultExtractor) Extract(ctx context.Context, ev Event) (io.Reader, func(), error) {
 	obj := e.storage.Bucket(ev.Bucket).Object(ev.Name)
+
+	// Read the exact generation the event refers to even if the object has been overwritten.
+	if ev.Generation != 0 {
+		obj = obj.Generation(ev.Generation)
+	}
+
 	r, err := obj.NewReader(ctx)
 	if err != nil {
 		return nil, nil, xerrors.Errorf("failed to get reader of %s: %w", ev.FullPath(), err)
@@ -35,3 +44,44 @@ func (e *defaultExtractor) Extract(ctx context.Context, ev Event) (io.Reader, fu
 
 	return r, func() { r.Close() }, nil
 }
+
+// FileExtractor extracts data from local files instead of Cloud Storage.
+// FileExtractor is useful to run handlers against a local mirror of buckets
+// in development and integration tests without any credentials.
+//
+// An object gs://<bucket>/<name> is read from <Root>/<bucket>/<name>.
+// If the event has no bucket, the object is read from <Root>/<name>.
+type FileExtractor struct {
+	Root string
+}
+
+// NewFileExtractor builds a FileExtractor rooted at the directory.
+func NewFileExtractor(root string) *FileExtractor {
+	return &FileExtractor{Root: root}
+}
+
+// Path returns the local path for the event.
+func (e *FileExtractor) Path(ev Event) (string, error) {
+	// Joining with "/" first keeps the path in Root even if the name contains "..".
+	rel := path.Join("/", ev.Bucket, ev.Name)
+	if ev.Name == "" {
+		return "", xerrors.Errorf("invalid object path: %s", ev.FullPath())
+	}
+
+	return filepath.Join(e.Root, filepath.FromSlash(rel)), nil
+}
+
+// Extract opens the local file for the event.
+func (e *FileExtractor) Extract(_ context.Context, ev Event) (io.Reader, func(), error) {
+	p, err := e.Path(ev)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to open %s for %s: %w", p, ev.FullPath(), err)
+	}
+
+	return f, func() { f.Close() }, nil
+}
@@ -0,0 +1,100 @@
+package bqloader
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func Test_FileExtractor(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "bucket", "test"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "bucket", "test", "name.csv"), []byte("a,b\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ex := NewFileExtractor(root)
+
+	cases := map[string]struct {
+		event            Event
+		expectedHasError bool
+	}{
+		"found":      {event: Event{Bucket: "bucket", Name: "test/name.csv"}},
+		"clean path": {event: Event{Bucket: "bucket", Name: "../bucket/test/name.csv"}},
+		"not found":  {event: Event{Bucket: "bucket", Name: "test/unknown.csv"}, expectedHasError: true},
+		"no bucket":  {event: Event{Name: "test/name.csv"}, expectedHasError: true},
+		"empty name": {event: Event{Bucket: "bucket"}, expectedHasError: true},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r, closer, err := ex.Extract(context.Background(), c.event)
+			if c.expectedHasError {
+				if err == nil {
+					t.Errorf("expected error but no error occurred")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer closer()
+
+			body, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != "a,b\n" {
+				t.Errorf(`body should be "a,b\n", but %q`, body)
+			}
+		})
+	}
+}
+
+func Test_FileExtractor_withBQLoader(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "bucket", "test"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "bucket", "test", "name.csv"), []byte("1,2\n3,4\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tl := newTestLoader()
+	handler := &Handler{
+		Name:      "test-handler",
+		Pattern:   regexp.MustCompile("^test/"),
+		Parser:    CSVParser(),
+		Projector: func(_ context.Context, r []string) ([]string, error) { return r, nil },
+		Extractor: NewFileExtractor(root),
+		Loader:    tl,
+	}
+
+	ctx := context.Background()
+
+	loader, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loader.MustAddHandler(ctx, handler)
+
+	if err := loader.Handle(ctx, Event{Bucket: "bucket", Name: "test/name.csv"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if res := tl.(*testLoader); len(res.result) != 2 {
+		t.Errorf("Size of result records should be 2, but %d", len(res.result))
+	}
+}
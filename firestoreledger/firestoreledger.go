@@ -0,0 +1,65 @@
+// Package firestoreledger provides a bqloader.Ledger storing records in Firestore.
+// It's separated from bqloader not to depend on the Firestore client unless used.
+package firestoreledger
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"go.nownabe.dev/bqloader"
+	"golang.org/x/xerrors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type ledger struct {
+	collection *firestore.CollectionRef
+}
+
+// New builds a Ledger which stores records as documents in a Firestore collection.
+// It connects to the Firestore emulator if FIRESTORE_EMULATOR_HOST is set.
+func New(ctx context.Context, project, collection string) (bqloader.Ledger, error) {
+	c, err := firestore.NewClient(ctx, project)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to build firestore client for %s: %w", project, err)
+	}
+
+	return &ledger{collection: c.Collection(collection)}, nil
+}
+
+func (l *ledger) Completed(ctx context.Context, k bqloader.LedgerKey) (bool, error) {
+	_, err := l.doc(k).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, xerrors.Errorf("failed to get ledger record of %s: %w", k, err)
+	}
+
+	return true, nil
+}
+
+func (l *ledger) Complete(ctx context.Context, k bqloader.LedgerKey) error {
+	_, err := l.doc(k).Set(ctx, map[string]interface{}{
+		"bucket":      k.Bucket,
+		"name":        k.Name,
+		"generation":  k.Generation,
+		"handler":     k.Handler,
+		"completedAt": time.Now(),
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to set ledger record of %s: %w", k, err)
+	}
+
+	return nil
+}
+
+// doc returns the document of the key. Document IDs are hashed because object names may contain slashes.
+func (l *ledger) doc(k bqloader.LedgerKey) *firestore.DocumentRef {
+	sum := sha256.Sum256([]byte(k.String()))
+
+	return l.collection.Doc(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,96 @@
+package firestoreledger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"go.nownabe.dev/bqloader"
+)
+
+// newTestLedger builds a ledger on the Firestore emulator with a collection unique to the test.
+func newTestLedger(t *testing.T) *ledger {
+	t.Helper()
+
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set")
+	}
+
+	l, err := New(context.Background(), "test-project", fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return l.(*ledger)
+}
+
+func TestLedger(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	l := newTestLedger(t)
+	k := bqloader.LedgerKey{Bucket: "bucket", Name: "dir/a.csv", Generation: 1, Handler: "h"}
+
+	if done, err := l.Completed(ctx, k); err != nil || done {
+		t.Fatalf("key should not be completed: %v, %v", done, err)
+	}
+
+	if err := l.Complete(ctx, k); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if done, err := l.Completed(ctx, k); err != nil || !done {
+		t.Errorf("key should be completed: %v, %v", done, err)
+	}
+
+	other := k
+	other.Generation = 2
+	if done, err := l.Completed(ctx, other); err != nil || done {
+		t.Errorf("another generation should not be completed: %v, %v", done, err)
+	}
+}
+
+func TestLedger_ConcurrentDuplicates(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	l := newTestLedger(t)
+	k := bqloader.LedgerKey{Bucket: "bucket", Name: "dir/a.csv", Generation: 1, Handler: "h"}
+
+	// Duplicate deliveries of the same event complete the same key concurrently.
+	const n = 10
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = l.Complete(ctx, k)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+
+	docs, err := l.collection.Documents(ctx).GetAll()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(docs) != 1 {
+		t.Errorf("expected 1 record, but %d", len(docs))
+	}
+
+	if done, err := l.Completed(ctx, k); err != nil || !done {
+		t.Errorf("key should be completed: %v, %v", done, err)
+	}
+}
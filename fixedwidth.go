@@ -0,0 +1,327 @@
+package bqloader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/xerrors"
+)
+
+var (
+	errNoFixedWidthRecords  = errors.New("no record types are defined")
+	errUnknownRecordType    = errors.New("unknown record type")
+	errInvalidFixedWidthCol = errors.New("invalid column")
+)
+
+// FixedWidthLayout defines the layout of fixed-width records for FixedWidthParser.
+type FixedWidthLayout struct {
+	// Encoding is the encoding of the source. Optional.
+	// Byte offsets are measured in this encoding, so set it instead of Handler.Encoding.
+	Encoding encoding.Encoding
+
+	// Runes measures offsets and widths of columns in runes instead of bytes.
+	Runes bool
+
+	// RecordLength splits the source into records of the number of bytes instead of lines.
+	// Line breaks between records are ignored.
+	RecordLength int
+
+	// Records defines record types. Each record is parsed with the first record type whose Code
+	// its leading characters match. A record type with an empty Code matches any records.
+	Records []FixedWidthRecord
+}
+
+// FixedWidthRecord defines a record type such as header, data, trailer and end records.
+type FixedWidthRecord struct {
+	// Code is the leading code identifying the record type.
+	Code string
+
+	// Skip drops records of this type.
+	Skip bool
+
+	// Columns defines columns of records of this type.
+	Columns []FixedWidthColumn
+}
+
+// FixedWidthColumn defines a column by the 0-based offset and the width.
+// Parts of the column beyond the end of a record are treated as empty.
+type FixedWidthColumn struct {
+	Start int
+	Width int
+	Trim  FixedWidthTrim
+}
+
+// FixedWidthTrim is a trimming rule of column values.
+type FixedWidthTrim int
+
+const (
+	// TrimSpace trims leading and trailing half-width and full-width spaces. This is the default.
+	TrimSpace FixedWidthTrim = iota
+	// TrimNone keeps values as is.
+	TrimNone
+	// TrimRightSpace trims trailing half-width and full-width spaces.
+	TrimRightSpace
+	// TrimLeadingZeros trims spaces and leading zeros of numbers leaving at least one digit.
+	TrimLeadingZeros
+)
+
+// FixedWidthParser provides a stream parser to parse fixed-width records.
+// Records are split by lines or by FixedWidthLayout.RecordLength, and parsed into columns
+// according to the record type of each record. Empty lines are skipped.
+// Records are read one by one without loading the whole file.
+func FixedWidthParser(layout FixedWidthLayout) StreamParser {
+	return func(_ context.Context, r io.Reader) (RowIterator, error) {
+		if err := layout.validate(); err != nil {
+			return nil, err
+		}
+
+		return &fixedWidthIterator{layout: &layout, r: bufio.NewReader(r)}, nil
+	}
+}
+
+func (l *FixedWidthLayout) validate() error {
+	if len(l.Records) == 0 {
+		return errNoFixedWidthRecords
+	}
+
+	for _, rt := range l.Records {
+		for i, c := range rt.Columns {
+			if c.Start < 0 || c.Width <= 0 {
+				return xerrors.Errorf("%w: column %d of record type %q", errInvalidFixedWidthCol, i, rt.Code)
+			}
+		}
+	}
+
+	return nil
+}
+
+type fixedWidthIterator struct {
+	layout *FixedWidthLayout
+	r      *bufio.Reader
+
+	// n is the number of raw records read including skipped ones.
+	n int
+}
+
+func (it *fixedWidthIterator) Next() ([]string, error) {
+	for {
+		raw, err := it.read()
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("failed to read fixed-width records: %w", err)
+		}
+
+		it.n++
+
+		record, ok, err := it.layout.parse(raw)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to parse record %d: %w", it.n, err)
+		}
+		if ok {
+			return record, nil
+		}
+	}
+}
+
+// read reads the next raw record. It returns io.EOF if no records remain.
+func (it *fixedWidthIterator) read() ([]byte, error) {
+	if it.layout.RecordLength > 0 {
+		return it.readRecord()
+	}
+
+	for {
+		line, err := it.r.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		eof := err != nil
+		line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+		if eof {
+			line = trimSUB(line)
+		}
+
+		if len(line) > 0 {
+			return line, nil
+		}
+		if eof {
+			return nil, io.EOF
+		}
+	}
+}
+
+// readRecord reads a record of RecordLength bytes ignoring line breaks between records.
+func (it *fixedWidthIterator) readRecord() ([]byte, error) {
+	for {
+		b, err := it.r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] != '\r' && b[0] != '\n' {
+			break
+		}
+		if _, err := it.r.Discard(1); err != nil {
+			return nil, err
+		}
+	}
+
+	raw := make([]byte, it.layout.RecordLength)
+
+	n, err := io.ReadFull(it.r, raw)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	raw = raw[:n]
+
+	if _, err := it.r.Peek(1); errors.Is(err, io.EOF) {
+		raw = trimSUB(raw)
+		if len(raw) == 0 {
+			return nil, io.EOF
+		}
+	}
+
+	return raw, nil
+}
+
+// trimSUB trims trailing EOF characters (SUB) of old systems at the end of the source.
+func trimSUB(b []byte) []byte {
+	return bytes.TrimRight(b, "\x1a")
+}
+
+// parse parses a raw record. It returns false if the record type is skipped.
+func (l *FixedWidthLayout) parse(raw []byte) ([]string, bool, error) {
+	var runes []rune
+
+	if l.Runes {
+		s, err := l.decode(raw)
+		if err != nil {
+			return nil, false, err
+		}
+		runes = []rune(s)
+	}
+
+	for _, rt := range l.Records {
+		if l.Runes && !strings.HasPrefix(string(runes), rt.Code) {
+			continue
+		}
+		if !l.Runes && !l.hasCode(raw, rt.Code) {
+			continue
+		}
+
+		if rt.Skip {
+			return nil, false, nil
+		}
+
+		record := make([]string, len(rt.Columns))
+
+		for i, c := range rt.Columns {
+			var v string
+
+			if l.Runes {
+				v = string(runes[clamp(c.Start, len(runes)):clamp(c.Start+c.Width, len(runes))])
+			} else {
+				s, err := l.decode(raw[clamp(c.Start, len(raw)):clamp(c.Start+c.Width, len(raw))])
+				if err != nil {
+					return nil, false, xerrors.Errorf("failed to decode column %d: %w", i, err)
+				}
+				v = s
+			}
+
+			record[i] = c.Trim.apply(v)
+		}
+
+		return record, true, nil
+	}
+
+	return nil, false, xerrors.Errorf("%w: %q", errUnknownRecordType, l.leading(raw))
+}
+
+// leading returns the leading character of a raw record decoded in the encoding.
+func (l *FixedWidthLayout) leading(raw []byte) string {
+	s, err := l.decode(raw)
+	if err != nil {
+		return string(raw[:1])
+	}
+
+	r, _ := utf8.DecodeRuneInString(s)
+
+	return string(r)
+}
+
+func (l *FixedWidthLayout) hasCode(raw []byte, code string) bool {
+	if l.Encoding == nil || code == "" {
+		return bytes.HasPrefix(raw, []byte(code))
+	}
+
+	encoded, err := l.Encoding.NewEncoder().String(code)
+	if err != nil {
+		return false
+	}
+
+	return bytes.HasPrefix(raw, []byte(encoded))
+}
+
+func (l *FixedWidthLayout) decode(b []byte) (string, error) {
+	if l.Encoding == nil {
+		if !utf8.Valid(b) {
+			return "", xerrors.New("invalid UTF-8")
+		}
+		return string(b), nil
+	}
+
+	s, err := l.Encoding.NewDecoder().Bytes(b)
+	if err != nil {
+		return "", err
+	}
+
+	return string(s), nil
+}
+
+func (t FixedWidthTrim) apply(v string) string {
+	const spaces = " \u3000"
+
+	switch t {
+	case TrimNone:
+		return v
+	case TrimRightSpace:
+		return strings.TrimRight(v, spaces)
+	case TrimLeadingZeros:
+		v = strings.Trim(v, spaces)
+		if v == "" {
+			return ""
+		}
+
+		sign := ""
+		if strings.HasPrefix(v, "-") || strings.HasPrefix(v, "+") {
+			sign, v = v[:1], v[1:]
+		}
+
+		v = strings.TrimLeft(v, "0")
+		if v == "" || v[0] == '.' {
+			v = "0" + v
+		}
+
+		if sign == "-" {
+			return sign + v
+		}
+		return v
+	default:
+		return strings.Trim(v, spaces)
+	}
+}
+
+func clamp(i, max int) int {
+	if i > max {
+		return max
+	}
+
+	return i
+}
@@ -0,0 +1,161 @@
+package bqloader
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestFixedWidthParser(t *testing.T) {
+	t.Parallel()
+
+	// Header, data and trailer records with kanji of 2 bytes and half-width katakana of 1 byte in Shift_JIS.
+	lines := []string{
+		"1給与振込  20220725",
+		"20001ﾔﾏﾀﾞ ﾀﾛｳ  000123000",
+		"20002山田花子  000045600",
+		"8000002",
+		"9",
+	}
+
+	sjis, err := japanese.ShiftJIS.NewEncoder().String(strings.Join(lines, "\r\n") + "\r\n\x1a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layout := FixedWidthLayout{
+		Encoding: japanese.ShiftJIS,
+		Records: []FixedWidthRecord{
+			{Code: "1", Columns: []FixedWidthColumn{{Start: 0, Width: 1}, {Start: 1, Width: 10}, {Start: 11, Width: 8}}},
+			{Code: "2", Columns: []FixedWidthColumn{
+				{Start: 0, Width: 1},
+				{Start: 1, Width: 4, Trim: TrimNone},
+				{Start: 5, Width: 10},
+				{Start: 15, Width: 9, Trim: TrimLeadingZeros},
+			}},
+			{Code: "8", Columns: []FixedWidthColumn{{Start: 0, Width: 1}, {Start: 1, Width: 6, Trim: TrimLeadingZeros}}},
+			{Code: "9", Skip: true},
+		},
+	}
+
+	expected := [][]string{
+		{"1", "給与振込", "20220725"},
+		{"2", "0001", "ﾔﾏﾀﾞ ﾀﾛｳ", "123000"},
+		{"2", "0002", "山田花子", "45600"},
+		{"8", "2"},
+	}
+
+	actual, err := readAll(FixedWidthParser(layout), sjis)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assertRecords(t, expected, actual)
+
+	t.Run("record length", func(t *testing.T) {
+		t.Parallel()
+
+		l := layout
+		l.RecordLength = 24
+		source := strings.NewReplacer("\r\n", "", "20220725", "20220725     ", "8000002", "8000002"+strings.Repeat(" ", 17)).Replace(sjis)
+
+		actual, err := readAll(FixedWidthParser(l), source)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		assertRecords(t, expected, actual)
+	})
+
+	t.Run("runes", func(t *testing.T) {
+		t.Parallel()
+
+		l := FixedWidthLayout{
+			Runes: true,
+			Records: []FixedWidthRecord{
+				{Code: "D", Columns: []FixedWidthColumn{{Start: 1, Width: 4}, {Start: 5, Width: 4, Trim: TrimRightSpace}}},
+				{Columns: []FixedWidthColumn{{Start: 0, Width: 1}}},
+			},
+		}
+
+		actual, err := readAll(FixedWidthParser(l), "D山田花子 -01\n\nE\n")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		assertRecords(t, [][]string{{"山田花子", " -01"}, {"E"}}, actual)
+	})
+
+	t.Run("unknown record type", func(t *testing.T) {
+		t.Parallel()
+
+		l := layout
+		l.Records = l.Records[:2]
+
+		it, err := FixedWidthParser(l)(context.Background(), bytes.NewBufferString(sjis))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		// Records before the unknown one are read first.
+		for i := 0; i < 3; i++ {
+			if _, err := it.Next(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+
+		if _, err := it.Next(); err == nil || !strings.Contains(err.Error(), `failed to parse record 4: unknown record type: "8"`) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("unknown record type of multibyte character", func(t *testing.T) {
+		t.Parallel()
+
+		source, err := japanese.ShiftJIS.NewEncoder().String("1\r\n給与\r\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = readAll(FixedWidthParser(layout), source)
+		if err == nil || !strings.Contains(err.Error(), `failed to parse record 2: unknown record type: "給"`) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("invalid column", func(t *testing.T) {
+		t.Parallel()
+
+		l := FixedWidthLayout{Records: []FixedWidthRecord{{Columns: []FixedWidthColumn{{Start: 0, Width: 0}}}}}
+
+		if _, err := FixedWidthParser(l)(context.Background(), bytes.NewBufferString("")); err == nil {
+			t.Errorf("expected error but no error occurred")
+		}
+	})
+}
+
+func TestFixedWidthTrim(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		trim     FixedWidthTrim
+		value    string
+		expected string
+	}{
+		{TrimSpace, " 　foo bar　 ", "foo bar"},
+		{TrimNone, " foo ", " foo "},
+		{TrimRightSpace, " foo　 ", " foo"},
+		{TrimLeadingZeros, " 000120 ", "120"},
+		{TrimLeadingZeros, "0000", "0"},
+		{TrimLeadingZeros, "-0012", "-12"},
+		{TrimLeadingZeros, "+0012", "12"},
+		{TrimLeadingZeros, "000.50", "0.50"},
+		{TrimLeadingZeros, "    ", ""},
+	}
+
+	for _, c := range cases {
+		if actual := c.trim.apply(c.value); actual != c.expected {
+			t.Errorf("trim %d of %q should be %q, but %q", c.trim, c.value, c.expected, actual)
+		}
+	}
+}
@@ -2,13 +2,15 @@ package bqloader
 
 import (
 	"context"
+	"errors"
+	"io"
 	"regexp"
-	"sync"
+	"sync/atomic"
 	"time"
 
+	"cloud.google.com/go/bigquery"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"golang.org/x/sync/errgroup"
 	"golang.org/x/text/encoding"
 	"golang.org/x/text/transform"
 	"golang.org/x/xerrors"
@@ -16,6 +18,12 @@ import (
 
 const defaultBatchSize = 10000
 
+var (
+	errNoParser    = errors.New("neither Parser nor StreamParser is specified")
+	errNoProjector = errors.New("none of Projector, NamedProjector, MultiProjector and TypedProjector is specified")
+	errNoHeader    = errors.New("NamedProjector requires a parser providing a header such as HeaderCSVParser")
+)
+
 // Handler defines how to handle events which match specified pattern.
 type Handler struct {
 	// Name is the handler's name.
@@ -29,6 +37,37 @@ type Handler struct {
 	SkipLeadingRows uint
 	Preprocessor    Preprocessor
 
+	// StreamParser parses files row by row. It's used instead of Parser if specified.
+	// With StreamParser and a Loader implementing StreamLoader,
+	// memory usage stays flat regardless of file size.
+	StreamParser StreamParser
+
+	// AppendLineNumber appends the source line number of each record as the last column
+	// so that loaded rows can be sorted in the order of the source file.
+	// The line number counts records from 0 including skipped leading rows,
+	// the same as line numbers in error messages.
+	AppendLineNumber bool
+
+	// NamedProjector transforms records accessing columns by names in the header.
+	// It's used instead of Projector if specified, and requires a parser providing a header
+	// such as HeaderCSVParser.
+	NamedProjector NamedProjector
+
+	// MultiProjector transforms a record into any number of records.
+	// It's used instead of Projector if specified.
+	MultiProjector MultiProjector
+
+	// TypedProjector transforms records into typed rows validated against Schema.
+	// It's used instead of Projector and NamedProjector if specified, and requires Schema.
+	TypedProjector TypedProjector
+
+	// ErrorPolicy decides whether to skip rows which Projector failed to project.
+	// Default is FailFast.
+	ErrorPolicy *ErrorPolicy
+
+	// DeadLetterSink receives rows skipped by ErrorPolicy. Optional.
+	DeadLetterSink DeadLetterSink
+
 	// BatchSize specifies how much records are processed in a groutine.
 	// Default is 10000.
 	BatchSize int
@@ -42,6 +81,48 @@ type Handler struct {
 	// Table specifies BigQuery table ID as destination.
 	Table string
 
+	// Schema declares the schema of the destination table. Optional.
+	// If specified, the table is created if missing, and loading fails
+	// if the table schema has drifted incompatibly from Schema.
+	// The table is checked at the first load of the handler.
+	Schema bigquery.Schema
+
+	// TimePartitioning and Clustering are used to create the table with Schema.
+	// Differences from the existing table are logged as warnings.
+	TimePartitioning *bigquery.TimePartitioning
+	Clustering       *bigquery.Clustering
+
+	// WriteDisposition specifies how the default loader writes into the destination table.
+	// Default is bigquery.WriteAppend. Only LoadJob supports others.
+	WriteDisposition bigquery.TableWriteDisposition
+
+	// Partitioner decides the partition to write records of each event. Optional.
+	// With bigquery.WriteTruncate, reloading a file atomically replaces exactly the partition.
+	// Only LoadJob supports Partitioner.
+	Partitioner Partitioner
+
+	// RetryPolicy retries the extract and load phases failing with transient errors.
+	// It can't be used with StorageWriteCommitted.
+	// Default is no retries.
+	RetryPolicy *RetryPolicy
+
+	// Ledger records completed loads to skip events delivered more than once. Optional.
+	Ledger Ledger
+
+	// Router routes projected records to Destinations to split a file into multiple tables. Optional.
+	// With Router, records are loaded into the destinations instead of Table,
+	// and Loader must not be specified. Destinations are loaded concurrently and independently,
+	// so ones loaded successfully are kept even if another fails, and are not loaded again in retries.
+	Router       Router
+	Destinations []*Destination
+
+	// destinationLoaders holds default loaders of Destinations without Loader by name.
+	destinationLoaders map[string]Loader
+
+	// LoadMethod selects how the default loader writes records when Loader is not specified.
+	// Default is LoadJob.
+	LoadMethod LoadMethod
+
 	Extractor Extractor
 	Loader    Loader
 	semaphore chan struct{}
@@ -50,6 +131,24 @@ type Handler struct {
 // Projector transforms source records into records for destination.
 type Projector func(context.Context, []string) ([]string, error)
 
+// MultiProjector transforms a source record into records for destination
+// such as a transaction split into a payment and a fee.
+// Returning no records skips the source record.
+// Records of a source record are loaded in the returned order, and share the line number of the source.
+type MultiProjector func(context.Context, []string) ([][]string, error)
+
+// multi adapts Projector to MultiProjector.
+func (p Projector) multi() MultiProjector {
+	return func(ctx context.Context, source []string) ([][]string, error) {
+		record, err := p(ctx, source)
+		if err != nil || record == nil {
+			return nil, err
+		}
+
+		return [][]string{record}, nil
+	}
+}
+
 // Preprocessor preprocesses event and store data into a map.
 type Preprocessor func(context.Context, Event) (context.Context, error)
 
@@ -70,24 +169,66 @@ func (h *Handler) Handle(ctx context.Context, e Event) error {
 	l = h.logger(ctx, l)
 	ctx = l.WithContext(ctx)
 
+	res := &Result{Event: e, Handler: h}
+
 	l.Info().Msgf("handler %s started to handle an event", h.Name)
 	defer func() {
 		now := time.Now()
-		e := l.Info().Time("handlerFinished", now)
+		e := l.Info().Time("handlerFinished", now).
+			Int("parsedRows", res.ParsedRows).
+			Int("skippedRows", res.SkippedRows).
+			Int("rejectedRows", len(res.RejectedRows)).
+			Int("loadedRows", res.LoadedRows).
+			Int64("bytesRead", res.BytesRead).
+			Int("extractAttempts", res.Attempts.Extract).
+			Int("loadAttempts", res.Attempts.Load)
+		if res.JobID != "" {
+			e.Str("jobId", res.JobID)
+		}
+		if res.Partition != "" {
+			e.Str("partition", res.Partition)
+		}
 		if t, ok := handlerStartedTimeFrom(ctx); ok {
 			e.TimeDiff("handlerElapsed", now, t)
 		}
 		e.Msgf("handler %s finished to handle an event", h.Name)
 	}()
 
-	err := h.process(ctx, e)
+	if done, err := h.completed(ctx, e); err != nil {
+		err = xerrors.Errorf("failed to handle: %w", err)
+		l.Err(err).Msg(err.Error())
+		return err
+	} else if done {
+		l.Info().Msgf("handler %s skipped an event already loaded", h.Name)
+		return nil
+	}
+
+	err := h.process(ctx, e, res)
 	if err != nil {
 		err = xerrors.Errorf("failed to handle: %w", err)
 		l.Err(err).Msg(err.Error())
+	} else if h.Ledger != nil && e.Generation != 0 {
+		// Not returning the error since a retry would load the same rows again.
+		if lerr := h.Ledger.Complete(ctx, newLedgerKey(h, e)); lerr != nil {
+			lerr = xerrors.Errorf("failed to record completion in ledger: %w", lerr)
+			l.Err(lerr).Msg(lerr.Error())
+		}
+	}
+	res.Error = err
+
+	// Rejected rows are sent also when the file fails with too many of them.
+	if len(res.RejectedRows) > 0 {
+		l.Warn().Msgf("handler %s rejected %d bad rows", h.Name, len(res.RejectedRows))
+
+		if h.DeadLetterSink != nil {
+			if serr := h.DeadLetterSink.Send(ctx, res.RejectedRows); serr != nil {
+				serr = xerrors.Errorf("failed to send rejected rows to dead-letter sink: %w", serr)
+				l.Err(serr).Msg(serr.Error())
+			}
+		}
 	}
 
 	if h.Notifier != nil {
-		res := &Result{Event: e, Handler: h, Error: err}
 		if nerr := h.Notifier.Notify(ctx, res); nerr != nil {
 			nerr = xerrors.Errorf("failed to notify: %w", nerr)
 			l.Err(nerr).Msg(nerr.Error())
@@ -97,37 +238,123 @@ func (h *Handler) Handle(ctx context.Context, e Event) error {
 	return err
 }
 
-func (h *Handler) process(ctx context.Context, e Event) error {
+func (h *Handler) completed(ctx context.Context, e Event) (bool, error) {
+	if h.Ledger == nil || e.Generation == 0 {
+		return false, nil
+	}
+
+	done, err := h.Ledger.Completed(ctx, newLedgerKey(h, e))
+	if err != nil {
+		return false, xerrors.Errorf("failed to consult ledger: %w", err)
+	}
+
+	return done, nil
+}
+
+func (h *Handler) process(ctx context.Context, e Event, res *Result) error {
+	started := time.Now()
 	ctx, err := h.preprocess(ctx, e)
+	res.Durations.Preprocess = time.Since(started)
 	if err != nil {
 		return xerrors.Errorf("failed to preprocess: %w", err)
 	}
 
-	r, closer, err := h.Extractor.Extract(ctx, e)
+	if h.Partitioner != nil {
+		p, err := h.Partitioner(ctx, e)
+		if err != nil {
+			return xerrors.Errorf("failed to decide partition: %w", err)
+		}
+		ctx = withPartition(ctx, p)
+		res.Partition = p
+	}
+
+	parser, err := h.streamParser()
+	if err != nil {
+		return xerrors.Errorf("failed to parse: %w", err)
+	}
+
+	routes, err := newRoutes(h)
+	if err != nil {
+		return xerrors.Errorf("failed to route: %w", err)
+	}
+
+	_, streaming := h.Loader.(StreamLoader)
+	if routes != nil {
+		streaming = routes.streaming()
+	}
+
+	// Records are not buffered with StreamLoader, so the whole file is processed again to retry loading.
+	for attempt := 1; ; attempt++ {
+		if streaming {
+			res.Attempts.Load = attempt
+		}
+
+		err := h.run(ctx, e, parser, routes, res)
+
+		var lerr *loadError
+		if err == nil || !streaming || !errors.As(err, &lerr) || !h.RetryPolicy.wait(ctx, "load", attempt, err) {
+			return err
+		}
+	}
+}
+
+// run extracts, parses, projects and loads the file once.
+func (h *Handler) run(ctx context.Context, e Event, parser StreamParser, routes *routes, res *Result) error {
+	st := &stats{}
+	ctx = withStats(ctx, st)
+	defer st.fill(res)
+
+	var (
+		r      io.Reader
+		closer func()
+	)
+
+	started := time.Now()
+	attempts, err := h.RetryPolicy.do(ctx, "extract", func() error {
+		var err error
+		r, closer, err = h.Extractor.Extract(ctx, e)
+		return err
+	})
+	res.Durations.Extract += time.Since(started)
+	res.Attempts.Extract += attempts
 	if err != nil {
 		return xerrors.Errorf("failed to extract: %w", err)
 	}
 	defer closer()
 
+	r = &countingReader{r: r, s: st}
+
 	if h.Encoding != nil {
 		r = transform.NewReader(r, h.Encoding.NewDecoder())
 	}
 
-	source, err := h.Parser(ctx, r)
+	started = time.Now()
+	rows, err := parser(ctx, r)
+	st.addParse(started)
 	if err != nil {
 		return xerrors.Errorf("failed to parse: %w", err)
 	}
+	if c, ok := rows.(io.Closer); ok {
+		defer c.Close()
+	}
 
-	records, err := h.project(ctx, source[h.SkipLeadingRows:])
+	projector, err := h.projector(rows)
 	if err != nil {
 		return xerrors.Errorf("failed to project: %w", err)
 	}
 
-	if err := h.Loader.Load(ctx, records); err != nil {
-		return xerrors.Errorf("failed to load: %w", err)
+	rej := newRejector(h, e)
+	p := &pipeline{h: h, projector: projector, routes: routes, rej: rej, st: st}
+
+	err = p.run(ctx, rows, res)
+	res.RejectedRows = rej.rejected()
+
+	// Rows loaded into destinations are counted by routes even if the file fails.
+	if err != nil && routes == nil {
+		atomic.StoreInt64(&st.loadedRows, 0)
 	}
 
-	return nil
+	return err
 }
 
 func (h *Handler) preprocess(ctx context.Context, e Event) (context.Context, error) {
@@ -138,51 +365,47 @@ func (h *Handler) preprocess(ctx context.Context, e Event) (context.Context, err
 	return h.Preprocessor(ctx, e)
 }
 
-func (h *Handler) project(ctx context.Context, source [][]string) ([][]string, error) {
-	records := [][]string{}
-	mu := sync.Mutex{}
-	eg := errgroup.Group{}
-	numBatches := h.calcBatches(len(source))
-
-	for i := 0; i < numBatches; i++ {
-		startLine := h.BatchSize * i
-		endLine := h.BatchSize * (i + 1)
-		if endLine > len(source) {
-			endLine = len(source)
+// projector returns the projector for records iterated by rows.
+func (h *Handler) projector(rows RowIterator) (MultiProjector, error) {
+	if h.TypedProjector != nil {
+		if h.Schema == nil {
+			return nil, errNoSchema
 		}
+		if h.AppendLineNumber {
+			return nil, errTypedLineNumber
+		}
+		return h.TypedProjector.typed(h.Schema).multi(), nil
+	}
 
-		h.semaphore <- struct{}{}
-
-		eg.Go(func() error {
-			defer func() { <-h.semaphore }()
-
-			batchRecords := [][]string{}
-
-			for j := startLine; j < endLine; j++ {
-				record, err := h.Projector(ctx, source[j])
-				if err != nil {
-					return xerrors.Errorf("failed to project row %d (line %d): %w", j, uint(j)+h.SkipLeadingRows, err)
-				}
+	if h.NamedProjector != nil {
+		it, ok := rows.(HeaderIterator)
+		if !ok {
+			return nil, errNoHeader
+		}
+		return h.NamedProjector.named(it.Header()).multi(), nil
+	}
 
-				if record != nil {
-					batchRecords = append(batchRecords, record)
-				}
-			}
+	if h.MultiProjector != nil {
+		return h.MultiProjector, nil
+	}
 
-			mu.Lock()
-			defer mu.Unlock()
+	if h.Projector == nil {
+		return nil, errNoProjector
+	}
 
-			records = append(records, batchRecords...)
+	return h.Projector.multi(), nil
+}
 
-			return nil
-		})
+func (h *Handler) streamParser() (StreamParser, error) {
+	if h.StreamParser != nil {
+		return h.StreamParser, nil
 	}
 
-	if err := eg.Wait(); err != nil {
-		return nil, xerrors.Errorf("failed to wait errgroup: %w", err)
+	if h.Parser != nil {
+		return h.Parser.Stream(), nil
 	}
 
-	return records, nil
+	return nil, errNoParser
 }
 
 func (h *Handler) logger(ctx context.Context, l *zerolog.Logger) *zerolog.Logger {
@@ -207,11 +430,3 @@ func (h *Handler) logger(ctx context.Context, l *zerolog.Logger) *zerolog.Logger
 
 	return &logger
 }
-
-func (h *Handler) calcBatches(length int) int {
-	r := length / h.BatchSize
-	if length%h.BatchSize != 0 {
-		r++
-	}
-	return r
-}
@@ -3,9 +3,13 @@ package bqloader
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func Test_Handler_WithSkipping(t *testing.T) {
@@ -151,3 +155,380 @@ func Test_Handler_WithPreprocessor(t *testing.T) {
 		t.Errorf(`results[0][2] should be "789", but "%s"`, res.result[0][3])
 	}
 }
+
+type testStreamLoader struct {
+	testLoader
+	closed bool
+}
+
+func (l *testStreamLoader) LoadStream(ctx context.Context, records <-chan []string) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case r, ok := <-records:
+			if !ok {
+				l.closed = true
+				return nil
+			}
+			l.result = append(l.result, r)
+		}
+	}
+}
+
+func Test_Handler_Streaming(t *testing.T) {
+	t.Parallel()
+
+	projector := func(_ context.Context, r []string) ([]string, error) {
+		return r, nil
+	}
+
+	rawCSV := &strings.Builder{}
+	rawCSV.WriteString("header1,header2\n")
+	for i := 0; i < 25; i++ {
+		fmt.Fprintf(rawCSV, "%d,foo\n", i)
+	}
+
+	tl := &testStreamLoader{}
+
+	handler := &Handler{
+		Name:            "test-handler",
+		StreamParser:    CSVStreamParser(),
+		Projector:       projector,
+		SkipLeadingRows: 1,
+		BatchSize:       10,
+		Extractor:       newTestExtractor(),
+		Loader:          tl,
+		semaphore:       make(chan struct{}, 1),
+	}
+	e := Event{Name: "test/name", Bucket: "bucket", source: bytes.NewBufferString(rawCSV.String())}
+
+	if err := handler.Handle(context.Background(), e); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !tl.closed {
+		t.Errorf("records channel should be closed")
+	}
+
+	if len(tl.result) != 25 {
+		t.Fatalf("Size of result records should be 25, but %d", len(tl.result))
+	}
+
+	seen := map[string]bool{}
+	for _, r := range tl.result {
+		seen[r[0]] = true
+	}
+	for i := 0; i < 25; i++ {
+		if !seen[fmt.Sprint(i)] {
+			t.Errorf("record %d is not loaded", i)
+		}
+	}
+}
+
+func Test_Handler_StreamingWithParseError(t *testing.T) {
+	t.Parallel()
+
+	projector := func(_ context.Context, r []string) ([]string, error) {
+		return r, nil
+	}
+
+	tl := &testStreamLoader{}
+
+	handler := &Handler{
+		Name:         "test-handler",
+		StreamParser: CSVStreamParser(),
+		Projector:    projector,
+		BatchSize:    1,
+		Extractor:    newTestExtractor(),
+		Loader:       tl,
+		semaphore:    make(chan struct{}, 1),
+	}
+	e := Event{Name: "test/name", Bucket: "bucket", source: bytes.NewBufferString("1,2\n3,4\n5,6,7\n")}
+
+	if err := handler.Handle(context.Background(), e); err == nil {
+		t.Fatalf("expected error but no error occurred")
+	}
+
+	if tl.closed {
+		t.Errorf("records channel should not be closed when parsing failed")
+	}
+}
+
+type closingIterator struct {
+	sliceIterator
+	closed bool
+}
+
+func (it *closingIterator) Close() error {
+	it.closed = true
+	return nil
+}
+
+func Test_Handler_ClosesRowIterator(t *testing.T) {
+	t.Parallel()
+
+	records := make([][]string, 100)
+	for i := range records {
+		records[i] = []string{strconv.Itoa(i)}
+	}
+
+	cases := map[string]struct {
+		loader Loader
+		err    bool
+	}{
+		"loaded": {&testLoader{}, false},
+		"failed": {failingLoader{}, true},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			it := &closingIterator{sliceIterator: sliceIterator{records: append([][]string{}, records...)}}
+
+			handler := &Handler{
+				Name:         "test-handler",
+				StreamParser: func(context.Context, io.Reader) (RowIterator, error) { return it, nil },
+				Projector:    func(_ context.Context, r []string) ([]string, error) { return r, nil },
+				BatchSize:    1,
+				Extractor:    newTestExtractor(),
+				Loader:       c.loader,
+				semaphore:    make(chan struct{}, 1),
+			}
+			e := Event{Name: "test/name", Bucket: "bucket", source: bytes.NewBufferString("")}
+
+			if err := handler.Handle(context.Background(), e); (err != nil) != c.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !it.closed {
+				t.Errorf("row iterator should be closed")
+			}
+		})
+	}
+}
+
+func Test_Parser_Stream(t *testing.T) {
+	t.Parallel()
+
+	it, err := CSVParser().Stream()(context.Background(), bytes.NewBufferString("1,2\n3,4\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := [][]string{{"1", "2"}, {"3", "4"}}
+	for i := range expected {
+		r, err := it.Next()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Join(r, ",") != strings.Join(expected[i], ",") {
+			t.Errorf("record %d should be %v, but %v", i, expected[i], r)
+		}
+	}
+
+	if _, err := it.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF, but %v", err)
+	}
+}
+
+func Test_Handler_PreservesOrder(t *testing.T) {
+	t.Parallel()
+
+	projector := func(_ context.Context, r []string) ([]string, error) {
+		n, err := strconv.Atoi(r[0])
+		if err != nil {
+			return nil, err
+		}
+
+		// Make earlier batches finish later.
+		time.Sleep(time.Duration(100-n) * 10 * time.Microsecond)
+
+		return r, nil
+	}
+
+	rawCSV := &strings.Builder{}
+	rawCSV.WriteString("header\n")
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(rawCSV, "%d\n", i)
+	}
+
+	tl := newTestLoader()
+
+	handler := &Handler{
+		Name:             "test-handler",
+		Parser:           CSVParser(),
+		Projector:        projector,
+		SkipLeadingRows:  1,
+		AppendLineNumber: true,
+		BatchSize:        3,
+		Extractor:        newTestExtractor(),
+		Loader:           tl,
+		semaphore:        make(chan struct{}, 8),
+	}
+	e := Event{Name: "test/name", Bucket: "bucket", source: bytes.NewBufferString(rawCSV.String())}
+
+	if err := handler.Handle(context.Background(), e); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	res := tl.(*testLoader)
+
+	if len(res.result) != 100 {
+		t.Fatalf("Size of result records should be 100, but %d", len(res.result))
+	}
+
+	for i, r := range res.result {
+		if r[0] != strconv.Itoa(i) {
+			t.Errorf(`results[%d][0] should be "%d", but "%s"`, i, i, r[0])
+		}
+
+		if r[1] != strconv.Itoa(i+1) {
+			t.Errorf(`results[%d][1] should be "%d", but "%s"`, i, i+1, r[1])
+		}
+	}
+}
+
+func Test_Handler_MultiProjector(t *testing.T) {
+	t.Parallel()
+
+	// Each row becomes as many records as its count.
+	projector := func(_ context.Context, r []string) ([][]string, error) {
+		n, err := strconv.Atoi(r[1])
+		if err != nil {
+			return nil, err
+		}
+
+		// Make earlier batches finish later.
+		time.Sleep(time.Duration(10-n) * 100 * time.Microsecond)
+
+		records := make([][]string, n)
+		for i := range records {
+			records[i] = []string{r[0], strconv.Itoa(i)}
+		}
+
+		return records, nil
+	}
+
+	const rawCSV = "name,count\na,2\nb,0\nc,1\nd,bad\ne,3\n"
+
+	tl := &testLoader{}
+	rn := &resultNotifier{}
+
+	handler := &Handler{
+		Name:             "test-handler",
+		Parser:           CSVParser(),
+		MultiProjector:   projector,
+		SkipLeadingRows:  1,
+		AppendLineNumber: true,
+		ErrorPolicy:      SkipBadRows(1),
+		Notifier:         rn,
+		BatchSize:        1,
+		Extractor:        newTestExtractor(),
+		Loader:           tl,
+		semaphore:        make(chan struct{}, 4),
+	}
+	e := Event{Name: "test/name", Bucket: "bucket", source: bytes.NewBufferString(rawCSV)}
+
+	if err := handler.Handle(context.Background(), e); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := [][]string{
+		{"a", "0", "1"},
+		{"a", "1", "1"},
+		{"c", "0", "3"},
+		{"e", "0", "5"},
+		{"e", "1", "5"},
+		{"e", "2", "5"},
+	}
+
+	if len(tl.result) != len(expected) {
+		t.Fatalf("Size of result records should be %d, but %d", len(expected), len(tl.result))
+	}
+
+	for i := range expected {
+		if strings.Join(tl.result[i], ",") != strings.Join(expected[i], ",") {
+			t.Errorf("results[%d] should be %v, but %v", i, expected[i], tl.result[i])
+		}
+	}
+
+	res := rn.result
+
+	if res.ParsedRows != 5 || res.SkippedRows != 1 || res.LoadedRows != len(expected) {
+		t.Errorf("unexpected stats: parsed %d, skipped %d, loaded %d", res.ParsedRows, res.SkippedRows, res.LoadedRows)
+	}
+
+	if len(res.RejectedRows) != 1 {
+		t.Fatalf("expected 1 rejected row, but %d", len(res.RejectedRows))
+	}
+	if r := res.RejectedRows[0]; r.Row != 3 || r.Line != 4 || !strings.Contains(r.Error, "line 4") {
+		t.Errorf("unexpected rejected row: %+v", r)
+	}
+}
+
+type resultNotifier struct {
+	result *Result
+}
+
+func (n *resultNotifier) Notify(_ context.Context, r *Result) error {
+	n.result = r
+	return nil
+}
+
+func Test_Handler_Stats(t *testing.T) {
+	t.Parallel()
+
+	projector := func(_ context.Context, r []string) ([]string, error) {
+		if r[0] == "" {
+			return nil, nil
+		}
+
+		return r, nil
+	}
+
+	const rawCSV = "header1,header2\n1,foo\n,bar\n3,baz\n"
+
+	tn := &resultNotifier{}
+
+	handler := &Handler{
+		Name:            "test-handler",
+		Parser:          CSVParser(),
+		Projector:       projector,
+		SkipLeadingRows: 1,
+		Notifier:        tn,
+		BatchSize:       defaultBatchSize,
+		Extractor:       newTestExtractor(),
+		Loader:          newTestLoader(),
+		semaphore:       make(chan struct{}, 1),
+	}
+	e := Event{Name: "test/name", Bucket: "bucket", source: bytes.NewBufferString(rawCSV)}
+
+	if err := handler.Handle(context.Background(), e); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	res := tn.result
+
+	if res.ParsedRows != 3 {
+		t.Errorf("ParsedRows should be 3, but %d", res.ParsedRows)
+	}
+
+	if res.SkippedRows != 1 {
+		t.Errorf("SkippedRows should be 1, but %d", res.SkippedRows)
+	}
+
+	if res.LoadedRows != 2 {
+		t.Errorf("LoadedRows should be 2, but %d", res.LoadedRows)
+	}
+
+	if res.BytesRead != int64(len(rawCSV)) {
+		t.Errorf("BytesRead should be %d, but %d", len(rawCSV), res.BytesRead)
+	}
+
+	if res.Durations.Load <= 0 {
+		t.Errorf("Durations.Load should be positive, but %s", res.Durations.Load)
+	}
+}
@@ -0,0 +1,184 @@
+package bqloader
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/rs/zerolog"
+	"golang.org/x/xerrors"
+)
+
+const (
+	maxRequestBodySize = 1 << 20
+
+	notificationEventTypeFinalize = "OBJECT_FINALIZE"
+	notificationPayloadJSON       = "JSON_API_V1"
+)
+
+var errMissingObject = errors.New("bucket and name are required")
+
+// pushRequest is a request body of Pub/Sub push subscriptions.
+type pushRequest struct {
+	Message      *pushMessage `json:"message"`
+	Subscription string       `json:"subscription"`
+}
+
+type pushMessage struct {
+	Attributes  map[string]string `json:"attributes"`
+	Data        []byte            `json:"data"`
+	MessageID   string            `json:"messageId"`
+	PublishTime time.Time         `json:"publishTime"`
+}
+
+type httpHandler struct {
+	loader BQLoader
+}
+
+// NewHTTPHandler builds an http.Handler to run BQLoader as a long-lived service such as on Cloud Run.
+//
+// POST / accepts any of:
+//   - Pub/Sub push requests carrying Cloud Storage notifications
+//   - CloudEvents of Cloud Storage in binary or structured content mode
+//   - Event as JSON
+//
+// It responds 204 if the event is loaded or ignored, 413 if the body exceeds 1 MiB
+// and 500 if loading fails so that the event is redelivered.
+// Notifications other than OBJECT_FINALIZE are acknowledged and ignored.
+// Malformed requests are also acknowledged with 204 and logged, since Pub/Sub redelivers them forever otherwise.
+//
+// GET /healthz and GET /readyz are health and readiness endpoints.
+// /readyz responds 503 until at least one handler is added.
+func NewHTTPHandler(l BQLoader) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if bq, ok := l.(*bqloader); ok && !bq.ready() {
+			http.Error(w, "no handlers", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, "ok")
+	})
+	mux.Handle("/", &httpHandler{loader: l})
+
+	return mux
+}
+
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if m := cehttp.NewMessageFromHttpRequest(r); m.ReadEncoding() != binding.EncodingUnknown {
+		serveCloudEvent(w, r, h.loader, m)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to read request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var push pushRequest
+	if err := json.Unmarshal(body, &push); err != nil {
+		h.discard(w, xerrors.Errorf("failed to decode request body: %w", err))
+		return
+	}
+
+	ctx := r.Context()
+
+	var e Event
+	if push.Message != nil {
+		var ok bool
+		e, ok, err = eventFromNotification(push.Message)
+		if err != nil {
+			h.discard(w, xerrors.Errorf("failed to convert notification %s: %w", push.Message.MessageID, err))
+			return
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		ctx = withPushRequest(ctx, &push)
+	} else if err := json.Unmarshal(body, &e); err != nil {
+		h.discard(w, xerrors.Errorf("failed to decode event: %w", err))
+		return
+	}
+
+	if e.Bucket == "" || e.Name == "" {
+		h.discard(w, errMissingObject)
+		return
+	}
+
+	if err := h.loader.Handle(ctx, e); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// discard acknowledges a request which can never be loaded and logs it.
+func (h *httpHandler) discard(w http.ResponseWriter, err error) {
+	if bq, ok := h.loader.(*bqloader); ok {
+		bq.logger.Warn().Err(err).Msg("discarded malformed request")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// eventFromNotification converts a Pub/Sub notification of Cloud Storage into Event.
+// It returns false if the notification is not for a finalized object.
+func eventFromNotification(m *pushMessage) (Event, bool, error) {
+	if m.Attributes["eventType"] != notificationEventTypeFinalize {
+		return Event{}, false, nil
+	}
+
+	var e Event
+
+	if m.Attributes["payloadFormat"] == notificationPayloadJSON && len(m.Data) > 0 {
+		if err := json.Unmarshal(m.Data, &e); err != nil {
+			return Event{}, false, xerrors.Errorf("failed to decode notification payload: %w", err)
+		}
+
+		return e, true, nil
+	}
+
+	e.Bucket = m.Attributes["bucketId"]
+	e.Name = m.Attributes["objectId"]
+
+	if g := m.Attributes["objectGeneration"]; g != "" {
+		n, err := strconv.ParseInt(g, 10, 64)
+		if err != nil {
+			return Event{}, false, xerrors.Errorf("invalid objectGeneration: %w", err)
+		}
+		e.Generation = n
+	}
+
+	return e, true, nil
+}
+
+func pushRequestMetadata(p *pushRequest) *zerolog.Event {
+	return zerolog.Dict().
+		Str("eventId", p.Message.MessageID).
+		Time("timestamp", p.Message.PublishTime).
+		Str("eventType", p.Message.Attributes["eventType"]).
+		Str("subscription", p.Subscription)
+}
@@ -0,0 +1,120 @@
+package bqloader
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func pushBody(attributes, data string) string {
+	return `{"message": {"attributes": ` + attributes + `, "data": "` + base64.StdEncoding.EncodeToString([]byte(data)) +
+		`", "messageId": "1", "publishTime": "2022-07-01T00:00:00Z"}, "subscription": "projects/p/subscriptions/s"}`
+}
+
+func TestNewHTTPHandler(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		method  string
+		path    string
+		headers map[string]string
+		body    string
+		loader  Loader
+		status  int
+		rows    int
+	}{
+		"pubsub json": {
+			http.MethodPost, "/", nil,
+			pushBody(`{"eventType": "OBJECT_FINALIZE", "payloadFormat": "JSON_API_V1"}`, cloudEventData),
+			&testLoader{}, http.StatusNoContent, 1,
+		},
+		"pubsub without payload": {
+			http.MethodPost, "/", nil,
+			pushBody(`{"eventType": "OBJECT_FINALIZE", "payloadFormat": "NONE", "bucketId": "bucket",
+				"objectId": "test/a.csv", "objectGeneration": "1"}`, ""),
+			&testLoader{}, http.StatusNoContent, 1,
+		},
+		"pubsub delete": {
+			http.MethodPost, "/", nil,
+			pushBody(`{"eventType": "OBJECT_DELETE", "payloadFormat": "JSON_API_V1"}`, cloudEventData),
+			&testLoader{}, http.StatusNoContent, 0,
+		},
+		"pubsub invalid payload": {
+			http.MethodPost, "/", nil,
+			pushBody(`{"eventType": "OBJECT_FINALIZE", "payloadFormat": "JSON_API_V1"}`, "{"),
+			&testLoader{}, http.StatusNoContent, 0,
+		},
+		"event": {
+			http.MethodPost, "/", nil, cloudEventData, &testLoader{}, http.StatusNoContent, 1,
+		},
+		"event with numbers": {
+			http.MethodPost, "/", nil, `{"name": "test/a.csv", "bucket": "bucket", "generation": 1, "size": 8}`,
+			&testLoader{}, http.StatusNoContent, 1,
+		},
+		"cloudevent": {
+			http.MethodPost, "/",
+			map[string]string{
+				"Content-Type": "application/json", "Ce-Specversion": "1.0", "Ce-Id": "1",
+				"Ce-Source": "//storage.googleapis.com/projects/_/buckets/bucket", "Ce-Type": StorageObjectFinalizedType,
+			},
+			cloudEventData, &testLoader{}, http.StatusNoContent, 1,
+		},
+		"malformed": {http.MethodPost, "/", nil, "{", &testLoader{}, http.StatusNoContent, 0},
+		"no name":   {http.MethodPost, "/", nil, `{"bucket": "bucket"}`, &testLoader{}, http.StatusNoContent, 0},
+		"invalid generation": {
+			http.MethodPost, "/", nil,
+			pushBody(`{"eventType": "OBJECT_FINALIZE", "payloadFormat": "NONE", "bucketId": "bucket",
+				"objectId": "test/a.csv", "objectGeneration": "x"}`, ""),
+			&testLoader{}, http.StatusNoContent, 0,
+		},
+		"too large": {
+			http.MethodPost, "/", nil, `{"name": "` + strings.Repeat("a", maxRequestBodySize) + `"}`,
+			&testLoader{}, http.StatusRequestEntityTooLarge, 0,
+		},
+		"failed to load":  {http.MethodPost, "/", nil, cloudEventData, failingLoader{}, http.StatusInternalServerError, 0},
+		"wrong method":    {http.MethodGet, "/", nil, "", &testLoader{}, http.StatusMethodNotAllowed, 0},
+		"health check":    {http.MethodGet, "/healthz", nil, "", &testLoader{}, http.StatusOK, 0},
+		"readiness check": {http.MethodGet, "/readyz", nil, "", &testLoader{}, http.StatusOK, 0},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+
+			NewHTTPHandler(newCloudEventLoader(t, c.loader)).ServeHTTP(rec, req)
+
+			if rec.Code != c.status {
+				t.Errorf("expected status %d, but %d: %s", c.status, rec.Code, rec.Body.String())
+			}
+
+			if tl, ok := c.loader.(*testLoader); ok && len(tl.result) != c.rows {
+				t.Errorf("expected %d rows, but %v", c.rows, tl.result)
+			}
+		})
+	}
+}
+
+func TestNewHTTPHandler_NotReady(t *testing.T) {
+	t.Parallel()
+
+	l, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	NewHTTPHandler(l).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, but %d", http.StatusServiceUnavailable, rec.Code)
+	}
+}
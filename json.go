@@ -0,0 +1,400 @@
+package bqloader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+var (
+	errNoJSONColumns       = errors.New("no columns specified")
+	errJSONExplodeMismatch = errors.New("columns explode different arrays")
+)
+
+// JSONParser provides a stream parser to parse a JSON array or newline-delimited JSON (NDJSON) into records.
+// Each element of the array or each line is flattened into a record with columns extracted by path expressions
+// like JSONPath such as "$.amount", "$.merchant.name", "$.tags[0]" or "$['display name']".
+//
+// A path with "[*]" explodes the array into records repeating the other columns,
+// for example "$.id" and "$.items[*].price" yield a record for each item.
+// Columns exploding arrays must explode the same array, and an empty or missing array yields a record
+// with empty values in exploded columns.
+// For a single object holding records such as {"data": [...]}, specify columns like "$.data[*].amount".
+//
+// Strings, numbers and booleans are extracted as is, nulls and missing values are empty,
+// and objects and arrays are extracted as JSON.
+// NDJSON is parsed line by line without loading the whole file.
+func JSONParser(columns ...string) StreamParser {
+	return func(_ context.Context, r io.Reader) (RowIterator, error) {
+		paths, err := parseJSONPaths(columns)
+		if err != nil {
+			return nil, err
+		}
+
+		br := bufio.NewReader(r)
+
+		array, err := isJSONArray(br)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to parse as JSON: %w", err)
+		}
+
+		dec := json.NewDecoder(br)
+		dec.UseNumber()
+
+		if array {
+			if _, err := dec.Token(); err != nil {
+				return nil, xerrors.Errorf("failed to parse as JSON: %w", err)
+			}
+		}
+
+		return &jsonIterator{dec: dec, array: array, paths: paths}, nil
+	}
+}
+
+// isJSONArray skips leading spaces and BOM, and reports whether the document is an array.
+func isJSONArray(br *bufio.Reader) (bool, error) {
+	for {
+		r, _, err := br.ReadRune()
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		switch r {
+		case ' ', '\t', '\r', '\n', '\ufeff':
+			continue
+		}
+
+		if err := br.UnreadRune(); err != nil {
+			return false, err
+		}
+
+		return r == '[', nil
+	}
+}
+
+type jsonIterator struct {
+	dec     *json.Decoder
+	array   bool
+	paths   []jsonPath
+	pending [][]string
+}
+
+func (it *jsonIterator) Next() ([]string, error) {
+	for len(it.pending) == 0 {
+		v, err := it.decode()
+		if err != nil {
+			return nil, err
+		}
+
+		it.pending = jsonRecords(v, it.paths)
+	}
+
+	r := it.pending[0]
+	it.pending = it.pending[1:]
+
+	return r, nil
+}
+
+func (it *jsonIterator) decode() (interface{}, error) {
+	if it.array && !it.dec.More() {
+		if _, err := it.dec.Token(); err != nil {
+			return nil, xerrors.Errorf("failed to parse as JSON: %w", err)
+		}
+		if _, err := it.dec.Token(); !errors.Is(err, io.EOF) {
+			return nil, xerrors.New("failed to parse as JSON: unexpected data after the array")
+		}
+
+		return nil, io.EOF
+	}
+
+	var v interface{}
+	if err := it.dec.Decode(&v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+
+		return nil, xerrors.Errorf("failed to parse as JSON: %w", err)
+	}
+
+	return v, nil
+}
+
+// jsonRecords flattens a value into records exploding arrays.
+func jsonRecords(v interface{}, paths []jsonPath) [][]string {
+	record := make([]string, len(paths))
+
+	var array jsonPath
+	exploded := false
+	var indices []int
+	var subpaths []jsonPath
+
+	for i, p := range paths {
+		w := p.wildcard()
+		if w < 0 {
+			record[i] = formatJSONValue(p.get(v))
+			continue
+		}
+
+		array = p[:w]
+		exploded = true
+		indices = append(indices, i)
+		subpaths = append(subpaths, p[w+1:])
+	}
+
+	if !exploded {
+		return [][]string{record}
+	}
+
+	elems, _ := array.get(v).([]interface{})
+	if len(elems) == 0 {
+		return [][]string{record}
+	}
+
+	records := make([][]string, 0, len(elems))
+
+	for _, e := range elems {
+		for _, sub := range jsonRecords(e, subpaths) {
+			r := make([]string, len(record))
+			copy(r, record)
+			for j, i := range indices {
+				r[i] = sub[j]
+			}
+			records = append(records, r)
+		}
+	}
+
+	return records
+}
+
+func formatJSONValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// jsonPath is a parsed path expression.
+type jsonPath []jsonSegment
+
+type jsonSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func parseJSONPaths(columns []string) ([]jsonPath, error) {
+	if len(columns) == 0 {
+		return nil, errNoJSONColumns
+	}
+
+	paths := make([]jsonPath, len(columns))
+
+	for i, c := range columns {
+		p, err := parseJSONPath(c)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid path %q: %w", c, err)
+		}
+		paths[i] = p
+	}
+
+	if err := validateJSONExplosion(paths); err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+// validateJSONExplosion validates that columns explode the same arrays at each level.
+func validateJSONExplosion(paths []jsonPath) error {
+	var array jsonPath
+	exploded := false
+	var subpaths []jsonPath
+
+	for _, p := range paths {
+		w := p.wildcard()
+		if w < 0 {
+			continue
+		}
+
+		if exploded && !array.equal(p[:w]) {
+			return xerrors.Errorf("%w: %s and %s", errJSONExplodeMismatch, array, p[:w])
+		}
+
+		array = p[:w]
+		exploded = true
+		subpaths = append(subpaths, p[w+1:])
+	}
+
+	if !exploded {
+		return nil
+	}
+
+	return validateJSONExplosion(subpaths)
+}
+
+func parseJSONPath(expr string) (jsonPath, error) {
+	s := strings.TrimPrefix(expr, "$")
+	p := jsonPath{}
+
+	// A path may start with a key without the leading "$.".
+	if s == expr && s != "" && s[0] != '[' {
+		s = "." + s
+	}
+
+	for s != "" {
+		switch s[0] {
+		case '.':
+			end := strings.IndexAny(s[1:], ".[") + 1
+			if end == 0 {
+				end = len(s)
+			}
+
+			key := s[1:end]
+			if key == "" {
+				return nil, xerrors.New("empty key")
+			}
+
+			p = append(p, jsonSegment{key: key})
+			s = s[end:]
+		case '[':
+			seg, rest, err := parseJSONBracket(s)
+			if err != nil {
+				return nil, err
+			}
+
+			p = append(p, seg)
+			s = rest
+		default:
+			return nil, xerrors.Errorf("unexpected character %q", s[0])
+		}
+	}
+
+	return p, nil
+}
+
+// parseJSONBracket parses a segment like [0], [*] or ['key'].
+func parseJSONBracket(s string) (jsonSegment, string, error) {
+	if len(s) > 1 && (s[1] == '\'' || s[1] == '"') {
+		end := strings.IndexByte(s[2:], s[1]) + 2
+		if end < 2 || end+1 >= len(s) || s[end+1] != ']' {
+			return jsonSegment{}, "", xerrors.New("unterminated quoted key")
+		}
+
+		return jsonSegment{key: s[2:end]}, s[end+2:], nil
+	}
+
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return jsonSegment{}, "", xerrors.New("unterminated bracket")
+	}
+
+	inner := s[1:end]
+	if inner == "*" {
+		return jsonSegment{wildcard: true}, s[end+1:], nil
+	}
+
+	i, err := strconv.Atoi(inner)
+	if err != nil {
+		return jsonSegment{}, "", xerrors.Errorf("invalid index %q", inner)
+	}
+
+	return jsonSegment{index: i, isIndex: true}, s[end+1:], nil
+}
+
+// wildcard returns the position of the first [*], or -1.
+func (p jsonPath) wildcard() int {
+	for i, seg := range p {
+		if seg.wildcard {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// get returns the value at the path without wildcards.
+// It returns nil if the value is missing.
+func (p jsonPath) get(v interface{}) interface{} {
+	for _, seg := range p {
+		switch {
+		case seg.isIndex:
+			a, ok := v.([]interface{})
+			if !ok {
+				return nil
+			}
+
+			i := seg.index
+			if i < 0 {
+				i += len(a)
+			}
+			if i < 0 || i >= len(a) {
+				return nil
+			}
+
+			v = a[i]
+		default:
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+
+			v = m[seg.key]
+		}
+	}
+
+	return v
+}
+
+func (p jsonPath) equal(q jsonPath) bool {
+	if len(p) != len(q) {
+		return false
+	}
+
+	for i := range p {
+		if p[i] != q[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p jsonPath) String() string {
+	buf := &bytes.Buffer{}
+	buf.WriteString("$")
+
+	for _, seg := range p {
+		switch {
+		case seg.wildcard:
+			buf.WriteString("[*]")
+		case seg.isIndex:
+			buf.WriteString("[" + strconv.Itoa(seg.index) + "]")
+		case strings.ContainsAny(seg.key, ".[]'\""):
+			buf.WriteString("['" + seg.key + "']")
+		default:
+			buf.WriteString("." + seg.key)
+		}
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,171 @@
+package bqloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestJSONParser(t *testing.T) {
+	t.Parallel()
+
+	const orders = `[
+		{"id": 1, "merchant": {"name": "Shop A"}, "paid": true, "items": [{"sku": "a", "price": 100}, {"sku": "b", "price": 2.5}]},
+		{"id": 2, "merchant": null, "paid": false, "items": []},
+		{"id": 3, "tags": ["x", "y"], "meta": {"k": "v"}}
+	]`
+
+	cases := map[string]struct {
+		columns  []string
+		source   string
+		expected [][]string
+		err      string
+	}{
+		"array": {
+			columns: []string{"$.id", "$.merchant.name", "paid", "$.tags[1]", "$['meta']"},
+			source:  orders,
+			expected: [][]string{
+				{"1", "Shop A", "true", "", ""},
+				{"2", "", "false", "", ""},
+				{"3", "", "", "y", `{"k":"v"}`},
+			},
+		},
+		"explode": {
+			columns: []string{"$.id", "$.items[*].sku", "$.items[*].price"},
+			source:  orders,
+			expected: [][]string{
+				{"1", "a", "100"},
+				{"1", "b", "2.5"},
+				{"2", "", ""},
+				{"3", "", ""},
+			},
+		},
+		"NDJSON": {
+			columns:  []string{"$.date", "$.amount", "$.tags[-1]"},
+			source:   "\ufeff{\"date\": \"2022-07-01\", \"amount\": -1200, \"tags\": [\"a\", \"b\"]}\n\n{\"date\": \"2022-07-02\", \"amount\": 1e3}\n",
+			expected: [][]string{{"2022-07-01", "-1200", "b"}, {"2022-07-02", "1e3", ""}},
+		},
+		"records in an object": {
+			columns:  []string{"$.account", "$.data[*].amount", "$.data[*].lines[*]"},
+			source:   `{"account": "main", "data": [{"amount": 1, "lines": ["a", "b"]}, {"amount": 2, "lines": ["c"]}]}`,
+			expected: [][]string{{"main", "1", "a"}, {"main", "1", "b"}, {"main", "2", "c"}},
+		},
+		"empty array": {
+			columns:  []string{"$.id"},
+			source:   " [ ] ",
+			expected: [][]string{},
+		},
+		"empty file": {
+			columns:  []string{"$.id"},
+			source:   "",
+			expected: [][]string{},
+		},
+		"no columns": {
+			columns: nil,
+			source:  orders,
+			err:     "no columns specified",
+		},
+		"different arrays": {
+			columns: []string{"$.items[*].sku", "$.tags[*]"},
+			source:  orders,
+			err:     "columns explode different arrays: $.items and $.tags",
+		},
+		"invalid path": {
+			columns: []string{"$.items[x]"},
+			source:  orders,
+			err:     `invalid path "$.items[x]"`,
+		},
+		"invalid JSON": {
+			columns: []string{"$.id"},
+			source:  "{\"id\": 1}\n{\"id\": }\n",
+			err:     "failed to parse as JSON",
+		},
+		"data after array": {
+			columns: []string{"$.id"},
+			source:  `[{"id": 1}] {"id": 2}`,
+			err:     "unexpected data after the array",
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := readAll(JSONParser(c.columns...), c.source)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Errorf("error should contain %q, but %v", c.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			assertRecords(t, c.expected, actual)
+		})
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"$":               "$",
+		"amount":          "$.amount",
+		"$.a.b[0]":        "$.a.b[0]",
+		`$["a.b"][*].c`:   "$['a.b'][*].c",
+		"[2]['x']":        "$[2].x",
+		"$.a[-1]":         "$.a[-1]",
+		"$..a":            "",
+		"$.a[":            "",
+		"$['a]":           "",
+		"$.a b":           "$.a b",
+		"$a":              "",
+		"$.items[*]x":     "",
+		"$.items[*][*].x": "$.items[*][*].x",
+	}
+
+	for expr, expected := range cases {
+		p, err := parseJSONPath(expr)
+		if expected == "" {
+			if err == nil {
+				t.Errorf("expected error for %q, but %s", expr, p)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", expr, err)
+			continue
+		}
+
+		if p.String() != expected {
+			t.Errorf("%q should be parsed as %s, but %s", expr, expected, p)
+		}
+	}
+}
+
+func readAll(parser StreamParser, source string) ([][]string, error) {
+	it, err := parser(context.Background(), bytes.NewBufferString(source))
+	if err != nil {
+		return nil, err
+	}
+
+	records := [][]string{}
+	for {
+		r, err := it.Next()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+}
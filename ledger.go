@@ -0,0 +1,201 @@
+package bqloader
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// Ledger records completed loads so that events delivered more than once are loaded only once.
+//
+// Handler consults Ledger before processing an event and records the load after the loader succeeds.
+// Events without generation are always processed because re-uploads cannot be distinguished from
+// duplicate deliveries. Consulting and recording are not atomic, so deliveries of the same event
+// processed at the same time may both be loaded.
+type Ledger interface {
+	// Completed reports whether the load identified by the key has already been completed.
+	Completed(context.Context, LedgerKey) (bool, error)
+
+	// Complete records the load identified by the key as completed.
+	Complete(context.Context, LedgerKey) error
+}
+
+// LedgerKey identifies a load of an object generation by a handler.
+type LedgerKey struct {
+	Bucket     string `json:"bucket"`
+	Name       string `json:"name"`
+	Generation int64  `json:"generation"`
+	Handler    string `json:"handler"`
+}
+
+func newLedgerKey(h *Handler, e Event) LedgerKey {
+	return LedgerKey{Bucket: e.Bucket, Name: e.Name, Generation: e.Generation, Handler: h.Name}
+}
+
+func (k LedgerKey) String() string {
+	return fmt.Sprintf("gs://%s/%s#%d (%s)", k.Bucket, k.Name, k.Generation, k.Handler)
+}
+
+type memoryLedger struct {
+	mu   sync.Mutex
+	done map[LedgerKey]struct{}
+}
+
+// NewMemoryLedger builds a Ledger which keeps records in memory.
+// It's useful for tests and long-lived processes which handle all events by themselves.
+func NewMemoryLedger() Ledger {
+	return &memoryLedger{done: map[LedgerKey]struct{}{}}
+}
+
+func (l *memoryLedger) Completed(_ context.Context, k LedgerKey) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, ok := l.done[k]
+
+	return ok, nil
+}
+
+func (l *memoryLedger) Complete(_ context.Context, k LedgerKey) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.done[k] = struct{}{}
+
+	return nil
+}
+
+type fileLedger struct {
+	path   string
+	mu     sync.Mutex
+	done   map[LedgerKey]struct{}
+	offset int64
+}
+
+// NewFileLedger builds a Ledger which appends records to a local file as JSON Lines.
+// Records appended by other processes sharing the file are read on every call.
+// A record torn by a crash is skipped, so that the load may be done again.
+func NewFileLedger(path string) Ledger {
+	return &fileLedger{path: path, done: map[LedgerKey]struct{}{}}
+}
+
+func (l *fileLedger) Completed(_ context.Context, k LedgerKey) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.load(); err != nil {
+		return false, err
+	}
+
+	_, ok := l.done[k]
+
+	return ok, nil
+}
+
+func (l *fileLedger) Complete(_ context.Context, k LedgerKey) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.load(); err != nil {
+		return err
+	}
+
+	if _, ok := l.done[k]; ok {
+		return nil
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return xerrors.Errorf("failed to open %s: %w", l.path, err)
+	}
+	defer f.Close()
+
+	b, err := json.Marshal(k)
+	if err != nil {
+		return xerrors.Errorf("failed to marshal ledger key: %w", err)
+	}
+	b = append(b, '\n')
+
+	// A torn record left by a crash is terminated not to join the record to it.
+	torn, err := endsWithoutNewline(f)
+	if err != nil {
+		return xerrors.Errorf("failed to read %s: %w", l.path, err)
+	}
+	if torn {
+		b = append([]byte{'\n'}, b...)
+	}
+
+	// A record is appended with a single write not to interleave with other processes.
+	if _, err := f.Write(b); err != nil {
+		return xerrors.Errorf("failed to write into %s: %w", l.path, err)
+	}
+
+	l.done[k] = struct{}{}
+
+	return nil
+}
+
+// load reads records appended since the last read.
+func (l *fileLedger) load() error {
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return xerrors.Errorf("failed to open %s: %w", l.path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(l.offset, io.SeekStart); err != nil {
+		return xerrors.Errorf("failed to read %s: %w", l.path, err)
+	}
+
+	r := bufio.NewReader(f)
+
+	for {
+		line, err := r.ReadBytes('\n')
+		// An incomplete record being written by another process is read next time.
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return xerrors.Errorf("failed to read %s: %w", l.path, err)
+		}
+
+		l.offset += int64(len(line))
+
+		// Torn records left by crashes are skipped. Their loads may be done again.
+		var k LedgerKey
+		if err := json.Unmarshal(line, &k); err != nil {
+			continue
+		}
+
+		l.done[k] = struct{}{}
+	}
+}
+
+// endsWithoutNewline reports whether the file ends with an incomplete line.
+func endsWithoutNewline(f *os.File) (bool, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	if fi.Size() == 0 {
+		return false, nil
+	}
+
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, fi.Size()-1); err != nil {
+		return false, err
+	}
+
+	return last[0] != '\n', nil
+}
@@ -0,0 +1,176 @@
+package bqloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLedger(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]func(t *testing.T) Ledger{
+		"memory": func(*testing.T) Ledger { return NewMemoryLedger() },
+		"file":   func(t *testing.T) Ledger { return NewFileLedger(filepath.Join(t.TempDir(), "ledger.jsonl")) },
+	}
+
+	for name, newLedger := range cases {
+		newLedger := newLedger
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			l := newLedger(t)
+			k := LedgerKey{Bucket: "bucket", Name: "a.csv", Generation: 1, Handler: "h"}
+
+			if done, err := l.Completed(ctx, k); err != nil || done {
+				t.Fatalf("key should not be completed: %v, %v", done, err)
+			}
+
+			if err := l.Complete(ctx, k); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if done, err := l.Completed(ctx, k); err != nil || !done {
+				t.Errorf("key should be completed: %v, %v", done, err)
+			}
+
+			other := k
+			other.Generation = 2
+			if done, err := l.Completed(ctx, other); err != nil || done {
+				t.Errorf("another generation should not be completed: %v, %v", done, err)
+			}
+		})
+	}
+}
+
+func TestFileLedger_Persistence(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	k := LedgerKey{Bucket: "bucket", Name: "a.csv", Generation: 1, Handler: "h"}
+
+	if err := NewFileLedger(path).Complete(ctx, k); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if done, err := NewFileLedger(path).Completed(ctx, k); err != nil || !done {
+		t.Errorf("key should be completed after reopening: %v, %v", done, err)
+	}
+
+	// Records by another process sharing the file are read after the first read.
+	l := NewFileLedger(path)
+	other := k
+	other.Generation = 2
+
+	if done, err := l.Completed(ctx, other); err != nil || done {
+		t.Fatalf("key should not be completed: %v, %v", done, err)
+	}
+
+	if err := NewFileLedger(path).Complete(ctx, other); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if done, err := l.Completed(ctx, other); err != nil || !done {
+		t.Errorf("key completed by another process should be completed: %v, %v", done, err)
+	}
+}
+
+func TestFileLedger_TornRecord(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	torn := LedgerKey{Bucket: "bucket", Name: "a.csv", Generation: 1, Handler: "h"}
+	k := LedgerKey{Bucket: "bucket", Name: "b.csv", Generation: 1, Handler: "h"}
+
+	// A crash left a record without its newline.
+	if err := os.WriteFile(path, []byte(`{"bucket":"bucket","name":"a.c`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewFileLedger(path).Complete(ctx, k); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	l := NewFileLedger(path)
+
+	if done, err := l.Completed(ctx, k); err != nil || !done {
+		t.Errorf("key should be completed after a torn record: %v, %v", done, err)
+	}
+
+	if done, err := l.Completed(ctx, torn); err != nil || done {
+		t.Errorf("torn key should not be completed: %v, %v", done, err)
+	}
+
+	if err := l.Complete(ctx, torn); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if done, err := NewFileLedger(path).Completed(ctx, torn); err != nil || !done {
+		t.Errorf("key should be completed after reopening: %v, %v", done, err)
+	}
+}
+
+type failingLoader struct{}
+
+func (failingLoader) Load(context.Context, [][]string) error {
+	return errors.New("failed")
+}
+
+func Test_Handler_Ledger(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ledger := NewMemoryLedger()
+
+	newHandler := func(l Loader) *Handler {
+		return &Handler{
+			Name:      "test-handler",
+			Parser:    CSVParser(),
+			Projector: func(_ context.Context, r []string) ([]string, error) { return r, nil },
+			BatchSize: defaultBatchSize,
+			Extractor: newTestExtractor(),
+			Loader:    l,
+			Ledger:    ledger,
+			semaphore: make(chan struct{}, 1),
+		}
+	}
+	newEvent := func(generation int64) Event {
+		return Event{Name: "test/a.csv", Bucket: "bucket", Generation: generation, source: bytes.NewBufferString("a,b\n")}
+	}
+
+	if err := newHandler(failingLoader{}).Handle(ctx, newEvent(1)); err == nil {
+		t.Fatalf("expected error but no error occurred")
+	}
+
+	tl := &testLoader{}
+	if err := newHandler(tl).Handle(ctx, newEvent(1)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tl.result) != 1 {
+		t.Fatalf("failed load should not be recorded, but loaded %d rows", len(tl.result))
+	}
+
+	tl = &testLoader{}
+	if err := newHandler(tl).Handle(ctx, newEvent(1)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tl.result != nil {
+		t.Errorf("duplicate event should be skipped, but loaded %v", tl.result)
+	}
+
+	for _, g := range []int64{2, 0} {
+		tl = &testLoader{}
+		if err := newHandler(tl).Handle(ctx, newEvent(g)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(tl.result) != 1 {
+			t.Errorf("generation %d should be loaded, but loaded %d rows", g, len(tl.result))
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/csv"
+	"io"
 
 	"cloud.google.com/go/bigquery"
 	"golang.org/x/xerrors"
@@ -14,39 +15,170 @@ type Loader interface {
 	Load(context.Context, [][]string) error
 }
 
+// StreamLoader is a Loader which can load records while they are being projected.
+// If Handler.Loader implements StreamLoader, LoadStream is used instead of Load
+// and records are never buffered as a whole.
+//
+// The channel is closed after the last record is sent.
+// If processing fails before that, the context is canceled without closing the channel
+// and LoadStream must return without committing received records.
+type StreamLoader interface {
+	Loader
+	LoadStream(context.Context, <-chan []string) error
+}
+
 type defaultLoader struct {
-	table *bigquery.Table
+	dataset     *bigquery.Dataset
+	table       string
+	disposition bigquery.TableWriteDisposition
+	checker     *tableChecker
+
+	// schema is set to load records as JSON for TypedProjector.
+	schema bigquery.Schema
+}
+
+// recordWriter writes records in the source format of load jobs.
+type recordWriter interface {
+	Write([]string) error
+	Flush() error
+}
+
+type csvRecordWriter struct {
+	w *csv.Writer
+}
+
+func (w *csvRecordWriter) Write(record []string) error {
+	if err := w.w.Write(record); err != nil {
+		return xerrors.Errorf("failed to write csv: %w", err)
+	}
+
+	return nil
 }
 
-func newDefaultLoader(ctx context.Context, project, dataset, table string) (Loader, error) {
-	bq, err := bigquery.NewClient(ctx, project)
+func (w *csvRecordWriter) Flush() error {
+	w.w.Flush()
+
+	if err := w.w.Error(); err != nil {
+		return xerrors.Errorf("failed to write csv: %w", err)
+	}
+
+	return nil
+}
+
+func newDefaultLoader(ctx context.Context, h *Handler) (Loader, error) {
+	bq, err := bigquery.NewClient(ctx, h.Project)
 	if err != nil {
 		return nil, xerrors.Errorf("failed to build bigquery client for %s.%s.%s: %w",
-			project, dataset, table, err)
+			h.Project, h.Dataset, h.Table, err)
 	}
 
-	t := bq.Dataset(dataset).Table(table)
+	l := &defaultLoader{
+		dataset:     bq.Dataset(h.Dataset),
+		table:       h.Table,
+		disposition: h.WriteDisposition,
+	}
+
+	if spec := newTableSpec(h); spec != nil {
+		l.checker = &tableChecker{table: l.dataset.Table(h.Table), spec: spec}
+	}
 
-	return &defaultLoader{table: t}, nil
+	if h.TypedProjector != nil {
+		l.schema = h.Schema
+	}
+
+	return l, nil
+}
+
+func (l *defaultLoader) newWriter(w io.Writer) recordWriter {
+	if l.schema != nil {
+		return newJSONRecordWriter(w, l.schema)
+	}
+
+	return &csvRecordWriter{w: csv.NewWriter(w)}
 }
 
 func (l *defaultLoader) Load(ctx context.Context, records [][]string) error {
 	buf := &bytes.Buffer{}
-	if err := csv.NewWriter(buf).WriteAll(records); err != nil {
-		return xerrors.Errorf("failed to write csv into buffer: %w", err)
+	w := l.newWriter(buf)
+
+	for _, r := range records {
+		if err := w.Write(r); err != nil {
+			return xerrors.Errorf("failed to write into buffer: %w", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return xerrors.Errorf("failed to write into buffer: %w", err)
 	}
 
-	rs := bigquery.NewReaderSource(buf)
-	rs.AllowQuotedNewlines = true
+	return l.load(ctx, buf)
+}
+
+func (l *defaultLoader) LoadStream(ctx context.Context, records <-chan []string) error {
+	pr, pw := io.Pipe()
+
+	go func() {
+		w := l.newWriter(pw)
+
+		for {
+			select {
+			case <-ctx.Done():
+				pw.CloseWithError(ctx.Err())
+				return
+			case r, ok := <-records:
+				if !ok {
+					pw.CloseWithError(w.Flush())
+					return
+				}
+
+				if err := w.Write(r); err != nil {
+					pw.CloseWithError(xerrors.Errorf("failed to write into pipe: %w", err))
+					return
+				}
+			}
+		}
+	}()
+
+	err := l.load(ctx, pr)
 
-	loader := l.table.LoaderFrom(rs)
+	// Unblock the writer goroutine if the load job finished without reading all data.
+	pr.CloseWithError(io.ErrClosedPipe)
+
+	return err
+}
+
+func (l *defaultLoader) load(ctx context.Context, r io.Reader) error {
+	if l.checker != nil {
+		if _, err := l.checker.ensure(ctx); err != nil {
+			return err
+		}
+	}
+
+	rs := bigquery.NewReaderSource(r)
+	if l.schema != nil {
+		rs.SourceFormat = bigquery.JSON
+	} else {
+		rs.AllowQuotedNewlines = true
+	}
+
+	table := l.table
+	if p, ok := partitionFrom(ctx); ok {
+		table += "$" + p
+	}
+
+	loader := l.dataset.Table(table).LoaderFrom(rs)
 	loader.LoadConfig.CreateDisposition = bigquery.CreateNever
+	loader.LoadConfig.WriteDisposition = l.disposition
 
 	job, err := loader.Run(ctx)
 	if err != nil {
 		return xerrors.Errorf("failed to run bigquery load job: %w", err)
 	}
 
+	if st, ok := statsFrom(ctx); ok {
+		st.setJobID(job.ID())
+	}
+
 	status, err := job.Wait(ctx)
 	if err != nil {
 		return xerrors.Errorf("failed to wait bigquery job: %w", err)
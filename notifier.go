@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"golang.org/x/xerrors"
@@ -23,6 +25,57 @@ type Result struct {
 	Event   Event
 	Handler *Handler
 	Error   error
+
+	// RejectedRows are rows skipped by Handler.ErrorPolicy.
+	RejectedRows []*RejectedRow
+
+	// ParsedRows is the number of parsed records excluding skipped leading rows.
+	ParsedRows int
+
+	// SkippedRows is the number of records which Projector returned nil for.
+	SkippedRows int
+
+	// LoadedRows is the number of loaded records. It's 0 if loading failed.
+	LoadedRows int
+
+	// BytesRead is the size of the source file read from Extractor.
+	BytesRead int64
+
+	// Durations is time spent in each phase.
+	Durations PhaseDurations
+
+	// JobID is the ID of BigQuery load job, or the write stream name with Storage Write API,
+	// if the loader is a built-in one.
+	JobID string
+
+	// Partition is the partition decorator decided by Handler.Partitioner.
+	Partition string
+
+	// Attempts is the number of attempts of phases retried by RetryPolicy.
+	Attempts PhaseAttempts
+
+	// DestinationRows is the number of records loaded into each destination by name
+	// if the handler has Router. It contains only destinations loaded successfully
+	// including ones loaded in previous attempts, even if another destination failed.
+	DestinationRows map[string]int
+}
+
+// PhaseAttempts holds the number of attempts of each phase.
+type PhaseAttempts struct {
+	Extract int
+	Load    int
+}
+
+// PhaseDurations is time spent in each phase of handling an event.
+// Because parsing, projecting and loading run concurrently,
+// Parse and Project are accumulated time of parser and projectors respectively,
+// and Load is the time from starting the loader to its completion.
+type PhaseDurations struct {
+	Preprocess time.Duration
+	Extract    time.Duration
+	Parse      time.Duration
+	Project    time.Duration
+	Load       time.Duration
 }
 
 // SlackNotifier is a notifier for Slack.
@@ -69,9 +122,13 @@ func (n *SlackNotifier) Notify(ctx context.Context, r *Result) error {
 	var text string
 	if r.Error == nil {
 		text = fmt.Sprintf(`:white_check_mark: %s handler successfully loaded %s`, r.Handler.Name, r.Event.Name)
+		if n := len(r.RejectedRows); n > 0 {
+			text += fmt.Sprintf(" with %d rejected rows (first error: %s)", n, r.RejectedRows[0].Error)
+		}
 	} else {
 		text = fmt.Sprintf(`:x: %s handler failed to load %s: %s`, r.Handler.Name, r.Event.Name, r.Error)
 	}
+	text += "\n" + r.summary()
 	m := &slackMessage{
 		Channel:   n.Channel,
 		IconEmoji: n.IconEmoji,
@@ -87,6 +144,41 @@ func (n *SlackNotifier) Notify(ctx context.Context, r *Result) error {
 	return nil
 }
 
+// summary renders statistics of the result.
+func (r *Result) summary() string {
+	s := fmt.Sprintf("rows: %d loaded, %d parsed, %d skipped, %d rejected / bytes read: %d",
+		r.LoadedRows, r.ParsedRows, r.SkippedRows, len(r.RejectedRows), r.BytesRead)
+
+	d := r.Durations
+	s += fmt.Sprintf("\ntime: preprocess %s, extract %s, parse %s, project %s, load %s",
+		d.Preprocess.Round(time.Millisecond), d.Extract.Round(time.Millisecond),
+		d.Parse.Round(time.Millisecond), d.Project.Round(time.Millisecond), d.Load.Round(time.Millisecond))
+
+	if r.JobID != "" {
+		s += "\njob: " + r.JobID
+	}
+
+	if r.Partition != "" {
+		s += "\npartition: " + r.Partition
+	}
+
+	if a := r.Attempts; a.Extract > 1 || a.Load > 1 {
+		s += fmt.Sprintf("\nattempts: extract %d, load %d", a.Extract, a.Load)
+	}
+
+	if r.DestinationRows != nil && r.Handler != nil {
+		rows := make([]string, 0, len(r.Handler.Destinations))
+		for _, d := range r.Handler.Destinations {
+			if n, ok := r.DestinationRows[d.Name]; ok {
+				rows = append(rows, fmt.Sprintf("%s %d", d.Name, n))
+			}
+		}
+		s += "\ndestinations: " + strings.Join(rows, ", ")
+	}
+
+	return s
+}
+
 func (n *SlackNotifier) postMessage(ctx context.Context, m *slackMessage) error {
 	l := log.Ctx(ctx)
 
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"go.nownabe.dev/bqloader"
 )
@@ -114,6 +116,7 @@ func TestSlackNotifier(t *testing.T) {
 	slackClient := newSlackClient()
 
 	for name, c := range cases {
+		c := c
 		c.notifier.HTTPClient = slackClient
 
 		t.Run(name, func(t *testing.T) {
@@ -129,3 +132,40 @@ func TestSlackNotifier(t *testing.T) {
 		})
 	}
 }
+
+func TestSlackNotifier_stats(t *testing.T) {
+	t.Parallel()
+
+	var msg slackMessage
+	client := newTestClient(func(req *http.Request) *http.Response {
+		body, _ := ioutil.ReadAll(req.Body)
+		_ = json.Unmarshal(body, &msg)
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"ok":true}`)),
+			Header:     http.Header{},
+		}
+	})
+
+	n := &bqloader.SlackNotifier{Channel: "#channel", Token: validSlackToken, HTTPClient: client}
+	r := &bqloader.Result{
+		Event:      bqloader.Event{Name: "testfile"},
+		Handler:    &bqloader.Handler{Name: "myhandler"},
+		ParsedRows: 10,
+		LoadedRows: 8,
+		BytesRead:  1234,
+		Durations:  bqloader.PhaseDurations{Load: 1500 * time.Millisecond},
+		JobID:      "job_abc",
+	}
+
+	if err := n.Notify(context.Background(), r); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, s := range []string{"8 loaded", "10 parsed", "bytes read: 1234", "load 1.5s", "job: job_abc"} {
+		if !strings.Contains(msg.Text, s) {
+			t.Errorf("message should contain %q, but %q", s, msg.Text)
+		}
+	}
+}
@@ -45,3 +45,12 @@ func WithConcurrency(n int) Option {
 		return nil
 	})
 }
+
+// WithLedger configures the Ledger of handlers which don't have their own one.
+func WithLedger(ledger Ledger) Option {
+	return optionFunc(func(bq *bqloader) error {
+		bq.ledger = ledger
+
+		return nil
+	})
+}
@@ -3,14 +3,42 @@ package bqloader
 import (
 	"context"
 	"encoding/csv"
+	"errors"
 	"io"
+	"strings"
 
 	"golang.org/x/xerrors"
 )
 
+var errNoHeaderRow = errors.New("no header row found")
+
 // Parser parses files from storage.
 type Parser func(context.Context, io.Reader) ([][]string, error)
 
+// StreamParser parses files from storage into a RowIterator.
+// Unlike Parser, StreamParser doesn't need to hold whole records of a file in memory.
+type StreamParser func(context.Context, io.Reader) (RowIterator, error)
+
+// RowIterator iterates records parsed by StreamParser.
+// If it also implements io.Closer, Handler closes it after the file is handled, even if handling fails midway.
+type RowIterator interface {
+	// Next returns the next record.
+	// Next returns io.EOF when there are no more records.
+	Next() ([]string, error)
+}
+
+// Stream adapts Parser to StreamParser.
+// Records are parsed at once and then iterated.
+func (p Parser) Stream() StreamParser {
+	return func(ctx context.Context, r io.Reader) (RowIterator, error) {
+		records, err := p(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		return &sliceIterator{records: records}, nil
+	}
+}
+
 // CSVParser provides a parser to parse CSV files.
 func CSVParser() Parser {
 	return func(_ context.Context, r io.Reader) ([][]string, error) {
@@ -21,3 +49,79 @@ func CSVParser() Parser {
 		return records, nil
 	}
 }
+
+// CSVStreamParser provides a stream parser to parse CSV files row by row.
+func CSVStreamParser() StreamParser {
+	return func(_ context.Context, r io.Reader) (RowIterator, error) {
+		return &csvIterator{reader: csv.NewReader(r)}, nil
+	}
+}
+
+// HeaderCSVParser provides a stream parser to parse CSV files with a header row.
+// The first record is treated as the header and used to access columns by names in NamedProjector.
+// The header is not counted in Handler.SkipLeadingRows.
+func HeaderCSVParser() StreamParser {
+	return func(_ context.Context, r io.Reader) (RowIterator, error) {
+		cr := csv.NewReader(r)
+
+		// Records may have fewer columns than the header. Missing columns are treated as empty.
+		cr.FieldsPerRecord = -1
+
+		it := &csvIterator{reader: cr}
+
+		header, err := it.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, errNoHeaderRow
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("failed to read header: %w", err)
+		}
+
+		if len(header) > 0 {
+			header[0] = strings.TrimPrefix(header[0], "\ufeff")
+		}
+
+		return &headerCSVIterator{csvIterator: it, header: header}, nil
+	}
+}
+
+type sliceIterator struct {
+	records [][]string
+	pos     int
+}
+
+func (it *sliceIterator) Next() ([]string, error) {
+	if it.pos >= len(it.records) {
+		return nil, io.EOF
+	}
+
+	r := it.records[it.pos]
+	it.records[it.pos] = nil
+	it.pos++
+
+	return r, nil
+}
+
+type csvIterator struct {
+	reader *csv.Reader
+}
+
+func (it *csvIterator) Next() ([]string, error) {
+	r, err := it.reader.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("failed to parse as CSV: %w", err)
+	}
+	return r, nil
+}
+
+type headerCSVIterator struct {
+	*csvIterator
+	header []string
+}
+
+func (it *headerCSVIterator) Header() []string {
+	return it.header
+}
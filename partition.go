@@ -0,0 +1,46 @@
+package bqloader
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"golang.org/x/xerrors"
+)
+
+// Partitioner decides a partition decorator such as "20220701" or "202207" for an event.
+type Partitioner func(context.Context, Event) (string, error)
+
+var partitionDecoratorLayouts = map[bigquery.TimePartitioningType]string{
+	bigquery.HourPartitioningType:  "2006010215",
+	bigquery.DayPartitioningType:   "20060102",
+	bigquery.MonthPartitioningType: "200601",
+	bigquery.YearPartitioningType:  "2006",
+}
+
+// PartitionFromName builds a Partitioner which parses the first capturing group of re
+// in the object name with layout, and formats it as a decorator of the partitioning type.
+//
+// For example, PartitionFromName(regexp.MustCompile(`(\d{4}-\d{2})\.csv$`), "2006-01", bigquery.MonthPartitioningType)
+// decides the partition "202207" for "statements/2022-07.csv".
+func PartitionFromName(re *regexp.Regexp, layout string, typ bigquery.TimePartitioningType) Partitioner {
+	return func(_ context.Context, e Event) (string, error) {
+		decorator, ok := partitionDecoratorLayouts[typ]
+		if !ok {
+			return "", xerrors.Errorf("unknown partitioning type: %s", typ)
+		}
+
+		m := re.FindStringSubmatch(e.Name)
+		if len(m) < 2 {
+			return "", xerrors.Errorf("%s doesn't match %s", e.Name, re)
+		}
+
+		t, err := time.Parse(layout, m[1])
+		if err != nil {
+			return "", xerrors.Errorf("failed to parse partition time: %w", err)
+		}
+
+		return t.Format(decorator), nil
+	}
+}
@@ -0,0 +1,119 @@
+package bqloader
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func TestPartitionFromName(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		re       string
+		layout   string
+		typ      bigquery.TimePartitioningType
+		name     string
+		expected string
+		err      bool
+	}{
+		"month":          {`(\d{4}-\d{2})\.csv$`, "2006-01", bigquery.MonthPartitioningType, "a/2022-07.csv", "202207", false},
+		"day":            {`/(\d{8})_`, "20060102", bigquery.DayPartitioningType, "a/20220701_x.csv", "20220701", false},
+		"year":           {`(\d{4})`, "2006", bigquery.YearPartitioningType, "2022.csv", "2022", false},
+		"unmatched":      {`(\d{4}-\d{2})\.csv$`, "2006-01", bigquery.MonthPartitioningType, "a/b.csv", "", true},
+		"invalid time":   {`(\d{4}-\d{2})\.csv$`, "2006-01", bigquery.MonthPartitioningType, "a/2022-13.csv", "", true},
+		"unknown type":   {`(\d{4})`, "2006", bigquery.TimePartitioningType("WEEK"), "2022.csv", "", true},
+		"no capture grp": {`\d{4}`, "2006", bigquery.YearPartitioningType, "2022.csv", "", true},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := PartitionFromName(regexp.MustCompile(c.re), c.layout, c.typ)
+
+			actual, err := p(context.Background(), Event{Name: c.name})
+			if c.err {
+				if err == nil {
+					t.Errorf("expected error but no error occurred")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if actual != c.expected {
+				t.Errorf("expected %q, but %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+type partitionLoader struct {
+	partition string
+}
+
+func (l *partitionLoader) Load(ctx context.Context, _ [][]string) error {
+	l.partition, _ = partitionFrom(ctx)
+
+	return nil
+}
+
+func Test_Handler_Partitioner(t *testing.T) {
+	t.Parallel()
+
+	tl := &partitionLoader{}
+	tn := &resultNotifier{}
+
+	handler := &Handler{
+		Name:        "test-handler",
+		Parser:      CSVParser(),
+		Projector:   func(_ context.Context, r []string) ([]string, error) { return r, nil },
+		Partitioner: PartitionFromName(regexp.MustCompile(`(\d{4}-\d{2})\.csv$`), "2006-01", bigquery.MonthPartitioningType),
+		Notifier:    tn,
+		BatchSize:   defaultBatchSize,
+		Extractor:   newTestExtractor(),
+		Loader:      tl,
+		semaphore:   make(chan struct{}, 1),
+	}
+	e := Event{Name: "test/2022-07.csv", Bucket: "bucket", source: bytes.NewBufferString("a,b\n")}
+
+	if err := handler.Handle(context.Background(), e); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if tl.partition != "202207" {
+		t.Errorf("loader should receive partition 202207, but %q", tl.partition)
+	}
+
+	if tn.result.Partition != "202207" {
+		t.Errorf("Result.Partition should be 202207, but %q", tn.result.Partition)
+	}
+}
+
+func Test_AddHandler_StorageWriteWithPartition(t *testing.T) {
+	t.Parallel()
+
+	l, err := New()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	h := &Handler{
+		Name:             "test-handler",
+		Pattern:          regexp.MustCompile(`^test/`),
+		Parser:           CSVParser(),
+		Extractor:        newTestExtractor(),
+		LoadMethod:       StorageWritePending,
+		WriteDisposition: bigquery.WriteTruncate,
+	}
+
+	if err := l.AddHandler(context.Background(), h); err == nil {
+		t.Errorf("expected error but no error occurred")
+	}
+}
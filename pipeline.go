@@ -0,0 +1,349 @@
+package bqloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/xerrors"
+)
+
+/*
+	pipeline holds state to process an event.
+	Parsing, projecting and loading run concurrently connected by bounded channels.
+	Channels are closed only when their producer completes successfully,
+	so that consumers never mistake a failure for the end of records.
+*/
+type pipeline struct {
+	h         *Handler
+	projector MultiProjector
+	routes    *routes
+	rej       *rejector
+	st        *stats
+}
+
+func (p *pipeline) run(ctx context.Context, rows RowIterator, res *Result) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	batches := make(chan *batch)
+	records := make(chan []string, p.h.BatchSize)
+
+	eg.Go(func() error {
+		if err := p.read(ctx, rows, batches); err != nil {
+			return xerrors.Errorf("failed to parse: %w", err)
+		}
+		return nil
+	})
+
+	eg.Go(func() error {
+		if err := p.project(ctx, batches, records); err != nil {
+			return xerrors.Errorf("failed to project: %w", err)
+		}
+		return nil
+	})
+
+	eg.Go(func() error {
+		started := time.Now()
+		defer func() { res.Durations.Load = time.Since(started) }()
+
+		if err := p.load(ctx, records, res); err != nil {
+			return &loadError{err: xerrors.Errorf("failed to load: %w", err)}
+		}
+		return nil
+	})
+
+	return eg.Wait()
+}
+
+// batch is a chunk of source records projected in a goroutine.
+type batch struct {
+	// offset is the row number of the first record in the source excluding skipped leading rows.
+	offset  int
+	records [][]string
+}
+
+// read reads records from rows and sends them to batches in chunks of BatchSize.
+func (p *pipeline) read(ctx context.Context, rows RowIterator, batches chan<- *batch) error {
+	var numRows uint
+	b := &batch{offset: 0, records: make([][]string, 0, p.h.BatchSize)}
+
+	for {
+		started := time.Now()
+		record, err := rows.Next()
+		p.st.addParse(started)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		numRows++
+		if numRows <= p.h.SkipLeadingRows {
+			continue
+		}
+
+		atomic.AddInt64(&p.st.parsedRows, 1)
+
+		b.records = append(b.records, record)
+
+		if len(b.records) == p.h.BatchSize {
+			if err := sendBatch(ctx, batches, b); err != nil {
+				return err
+			}
+			b = &batch{offset: b.offset + len(b.records), records: make([][]string, 0, p.h.BatchSize)}
+		}
+	}
+
+	if len(b.records) > 0 {
+		if err := sendBatch(ctx, batches, b); err != nil {
+			return err
+		}
+	}
+
+	close(batches)
+
+	return nil
+}
+
+func sendBatch(ctx context.Context, batches chan<- *batch, b *batch) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case batches <- b:
+		return nil
+	}
+}
+
+// project projects batches concurrently up to the handler's concurrency
+// and sends projected records to the loader in the order of the source.
+func (p *pipeline) project(ctx context.Context, batches <-chan *batch, records chan<- []string) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	sem := p.h.semaphore
+
+	// pending queues results of batches in the order of the source.
+	pending := make(chan chan [][]string, cap(sem))
+
+	eg.Go(func() error {
+		return p.emit(ctx, pending, records)
+	})
+
+	total := 0
+
+	err := func() error {
+		for {
+			var b *batch
+			var ok bool
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case b, ok = <-batches:
+				if !ok {
+					close(pending)
+					return nil
+				}
+			}
+
+			total += len(b.records)
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case sem <- struct{}{}:
+			}
+
+			result := make(chan [][]string, 1)
+
+			select {
+			case <-ctx.Done():
+				<-sem
+				return ctx.Err()
+			case pending <- result:
+			}
+
+			eg.Go(func() error {
+				defer func() { <-sem }()
+
+				projected, err := p.projectBatch(ctx, b)
+				if err != nil {
+					return err
+				}
+				result <- projected
+
+				return nil
+			})
+		}
+	}()
+
+	if werr := eg.Wait(); werr != nil {
+		return werr
+	}
+	if err != nil {
+		return err
+	}
+
+	// Check before closing records not to let the loader commit.
+	if err := p.rej.check(total); err != nil {
+		return err
+	}
+
+	close(records)
+
+	return nil
+}
+
+// emit sends projected records of pending batches in order.
+func (p *pipeline) emit(ctx context.Context, pending <-chan chan [][]string, records chan<- []string) error {
+	for {
+		var result chan [][]string
+		var ok bool
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case result, ok = <-pending:
+			if !ok {
+				return nil
+			}
+		}
+
+		var projected [][]string
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case projected = <-result:
+		}
+
+		for _, r := range projected {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case records <- r:
+				atomic.AddInt64(&p.st.loadedRows, 1)
+			}
+		}
+	}
+}
+
+func (p *pipeline) projectBatch(ctx context.Context, b *batch) ([][]string, error) {
+	started := time.Now()
+	defer p.st.addProject(started)
+
+	projected := make([][]string, 0, len(b.records))
+
+	for i, source := range b.records {
+		j := b.offset + i
+		line := uint(j) + p.h.SkipLeadingRows
+
+		records, err := p.projector(ctx, source)
+		if err != nil {
+			err = xerrors.Errorf("failed to project row %d (line %d): %w", j, line, err)
+			if err := p.rej.reject(j, line, source, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if len(records) == 0 {
+			atomic.AddInt64(&p.st.skippedRows, 1)
+			continue
+		}
+
+		if p.routes != nil {
+			if records, err = p.route(ctx, records); err != nil {
+				err = xerrors.Errorf("failed to route row %d (line %d): %w", j, line, err)
+				if err := p.rej.reject(j, line, source, err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+
+		for _, record := range records {
+			if p.h.AppendLineNumber {
+				record = append(record, strconv.FormatUint(uint64(line), 10))
+			}
+
+			projected = append(projected, record)
+		}
+	}
+
+	return projected, nil
+}
+
+// route routes all records projected from a source record, or none of them if any fails.
+func (p *pipeline) route(ctx context.Context, records [][]string) ([][]string, error) {
+	routed := make([][]string, 0, len(records))
+
+	for _, r := range records {
+		tagged, err := p.routes.route(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		routed = append(routed, tagged)
+	}
+
+	return routed, nil
+}
+
+// load loads records into the handler's loader, or into destinations routed by Router.
+func (p *pipeline) load(ctx context.Context, records <-chan []string, res *Result) error {
+	if p.routes != nil {
+		return p.loadRoutes(ctx, records, res)
+	}
+
+	attempts, err := p.loadInto(ctx, p.h.Loader, records)
+	if attempts > 0 {
+		res.Attempts.Load = attempts
+	}
+
+	return err
+}
+
+// loadInto loads records with StreamLoader if available, or buffers all records and loads them at once.
+// It returns the number of attempts of buffered loading, or 0 with StreamLoader.
+func (p *pipeline) loadInto(ctx context.Context, l Loader, records <-chan []string) (int, error) {
+	if sl, ok := l.(StreamLoader); ok {
+		if err := sl.LoadStream(ctx, records); err != nil {
+			return 0, err
+		}
+
+		// Drain records not to block projectors when the loader returns early.
+		return 0, drain(ctx, records)
+	}
+
+	buf := [][]string{}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case r, ok := <-records:
+			if !ok {
+				return p.h.RetryPolicy.do(ctx, "load", func() error {
+					return l.Load(ctx, buf)
+				})
+			}
+			buf = append(buf, r)
+		}
+	}
+}
+
+// drain receives records until the channel is closed.
+func drain(ctx context.Context, records <-chan []string) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-records:
+			if !ok {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package bqloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy retries phases which fail with transient errors.
+// The extract phase retries Extractor.Extract.
+// The load phase retries Loader.Load with buffered records. With a StreamLoader,
+// the file is extracted and parsed again since records are not buffered.
+// Only errors returned by loaders are retried in the load phase, not ones of parsers and projectors.
+//
+// RetryPolicy can't be used with StorageWriteCommitted because rows appended before a failure remain
+// and retries would load them twice.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts including the first one.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry.
+	// Delays grow by Multiplier up to MaxBackoff, and are randomized between half and full of them.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Retryable decides whether errors are transient. Default is IsRetryable.
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy retries up to 3 attempts with exponential backoff from 1 second.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+	}
+}
+
+var errCommittedRetry = errors.New("RetryPolicy can't be used with StorageWriteCommitted since retries may load rows twice")
+
+var retryableReasons = map[string]bool{
+	"backendError":      true,
+	"internalError":     true,
+	"rateLimitExceeded": true,
+}
+
+// IsRetryable reports whether err is a transient error of Google Cloud APIs or networks,
+// such as HTTP 429 and 5xx, BigQuery backendError and gRPC Unavailable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		if gerr.Code == http.StatusTooManyRequests || gerr.Code >= http.StatusInternalServerError {
+			return true
+		}
+		for _, e := range gerr.Errors {
+			if retryableReasons[e.Reason] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var berr *bigquery.Error
+	if errors.As(err, &berr) {
+		return retryableReasons[berr.Reason]
+	}
+
+	var serr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &serr) {
+		switch serr.GRPCStatus().Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.Internal:
+			return true
+		}
+		return false
+	}
+
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// do calls f until it succeeds or the policy gives up, and returns the number of attempts.
+func (p *RetryPolicy) do(ctx context.Context, phase string, f func() error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || !p.wait(ctx, phase, attempt, err) {
+			return attempt, err
+		}
+	}
+}
+
+// wait waits for the backoff and reports true if the attempt failed with err should be retried.
+func (p *RetryPolicy) wait(ctx context.Context, phase string, attempt int, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	if !retryable(err) {
+		return false
+	}
+
+	d := p.backoff(attempt)
+	log.Ctx(ctx).Warn().
+		Str("phase", phase).
+		Int("attempt", attempt).
+		Dur("backoff", d).
+		Err(err).
+		Msgf("retrying %s after %s (attempt %d/%d)", phase, d, attempt, p.MaxAttempts)
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// loadError marks errors returned by loaders to retry the load phase only with them.
+type loadError struct {
+	err error
+}
+
+func (e *loadError) Error() string {
+	return e.err.Error()
+}
+
+func (e *loadError) Unwrap() error {
+	return e.err
+}
+
+// backoff returns a randomized delay before the next attempt of attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	mul := p.Multiplier
+	if mul < 1 {
+		mul = 1
+	}
+
+	d := float64(p.InitialBackoff) * math.Pow(mul, float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+
+	// Equal jitter: keep half and randomize the other half.
+	return time.Duration(d/2 + rand.Float64()*d/2)
+}
@@ -0,0 +1,231 @@
+package bqloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"golang.org/x/xerrors"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errTransient = &googleapi.Error{Code: http.StatusServiceUnavailable}
+
+func TestIsRetryable(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"503":              {xerrors.Errorf("failed: %w", errTransient), true},
+		"429":              {&googleapi.Error{Code: http.StatusTooManyRequests}, true},
+		"400":              {&googleapi.Error{Code: http.StatusBadRequest}, false},
+		"400 backendError": {&googleapi.Error{Code: http.StatusBadRequest, Errors: []googleapi.ErrorItem{{Reason: "backendError"}}}, true},
+		"bigquery backend": {xerrors.Errorf("failed: %w", &bigquery.Error{Reason: "backendError"}), true},
+		"bigquery invalid": {&bigquery.Error{Reason: "invalid"}, false},
+		"grpc unavailable": {xerrors.Errorf("failed: %w", status.Error(codes.Unavailable, "unavailable")), true},
+		"grpc invalid":     {status.Error(codes.InvalidArgument, "invalid"), false},
+		"unexpected EOF":   {xerrors.Errorf("failed: %w", io.ErrUnexpectedEOF), true},
+		"canceled":         {xerrors.Errorf("failed: %w", context.Canceled), false},
+		"other":            {errors.New("failed"), false},
+		"no error":         {nil, false},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if actual := IsRetryable(c.err); actual != c.expected {
+				t.Errorf("expected %v, but %v", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	t.Parallel()
+
+	p := &RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 3 * time.Second, Multiplier: 2}
+
+	for attempt, max := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 3 * time.Second, 10: 3 * time.Second} {
+		d := p.backoff(attempt)
+		if d < max/2 || d > max {
+			t.Errorf("backoff of attempt %d should be between %s and %s, but %s", attempt, max/2, max, d)
+		}
+	}
+}
+
+type flakyExtractor struct {
+	Extractor
+	failures int
+	calls    int
+}
+
+func (e *flakyExtractor) Extract(ctx context.Context, ev Event) (io.Reader, func(), error) {
+	e.calls++
+	if e.calls <= e.failures {
+		return nil, nil, errTransient
+	}
+
+	return e.Extractor.Extract(ctx, ev)
+}
+
+type flakyLoader struct {
+	testLoader
+	err      error
+	failures int
+	calls    int
+}
+
+func (l *flakyLoader) Load(ctx context.Context, rs [][]string) error {
+	l.calls++
+	if l.calls <= l.failures {
+		return l.err
+	}
+
+	return l.testLoader.Load(ctx, rs)
+}
+
+type flakyStreamLoader struct {
+	testStreamLoader
+	failures int
+	calls    int
+}
+
+func (l *flakyStreamLoader) LoadStream(ctx context.Context, records <-chan []string) error {
+	l.calls++
+	if l.calls <= l.failures {
+		return errTransient
+	}
+
+	return l.testStreamLoader.LoadStream(ctx, records)
+}
+
+func Test_Handler_RetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.csv"), []byte("1,foo\n2,bar\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 2}
+
+	cases := map[string]struct {
+		extractFailures int
+		loader          Loader
+		policy          *RetryPolicy
+		err             bool
+		attempts        PhaseAttempts
+	}{
+		"extract":       {1, &testLoader{}, policy, false, PhaseAttempts{Extract: 2, Load: 1}},
+		"load":          {0, &flakyLoader{err: errTransient, failures: 2}, policy, false, PhaseAttempts{Extract: 1, Load: 3}},
+		"stream load":   {0, &flakyStreamLoader{failures: 1}, policy, false, PhaseAttempts{Extract: 2, Load: 2}},
+		"give up":       {0, &flakyLoader{err: errTransient, failures: 3}, policy, true, PhaseAttempts{Extract: 1, Load: 3}},
+		"not retryable": {0, &flakyLoader{err: errors.New("invalid"), failures: 1}, policy, true, PhaseAttempts{Extract: 1, Load: 1}},
+		"no policy":     {1, &testLoader{}, nil, true, PhaseAttempts{Extract: 1, Load: 0}},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tn := &resultNotifier{}
+			handler := &Handler{
+				Name:        "test-handler",
+				Parser:      CSVParser(),
+				Projector:   func(_ context.Context, r []string) ([]string, error) { return r, nil },
+				Notifier:    tn,
+				BatchSize:   defaultBatchSize,
+				Extractor:   &flakyExtractor{Extractor: NewFileExtractor(root), failures: c.extractFailures},
+				Loader:      c.loader,
+				RetryPolicy: c.policy,
+				semaphore:   make(chan struct{}, 1),
+			}
+
+			err := handler.Handle(context.Background(), Event{Name: "a.csv"})
+			if c.err && err == nil {
+				t.Errorf("expected error but no error occurred")
+			}
+			if !c.err && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if tn.result.Attempts != c.attempts {
+				t.Errorf("expected attempts %+v, but %+v", c.attempts, tn.result.Attempts)
+			}
+
+			if !c.err && tn.result.LoadedRows != 2 {
+				t.Errorf("LoadedRows should be 2, but %d", tn.result.LoadedRows)
+			}
+		})
+	}
+}
+
+func Test_Handler_RetryPolicy_ParseError(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.csv"), []byte("1,foo\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A truncated file is not a transient error of the loader even though io.ErrUnexpectedEOF is retryable.
+	parser := func(context.Context, io.Reader) ([][]string, error) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	tn := &resultNotifier{}
+	handler := &Handler{
+		Name:        "test-handler",
+		Parser:      parser,
+		Projector:   func(_ context.Context, r []string) ([]string, error) { return r, nil },
+		Notifier:    tn,
+		BatchSize:   defaultBatchSize,
+		Extractor:   NewFileExtractor(root),
+		Loader:      &flakyStreamLoader{},
+		RetryPolicy: &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+		semaphore:   make(chan struct{}, 1),
+	}
+
+	if err := handler.Handle(context.Background(), Event{Name: "a.csv"}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected unexpected EOF, but %v", err)
+	}
+
+	if expected := (PhaseAttempts{Extract: 1, Load: 1}); tn.result.Attempts != expected {
+		t.Errorf("expected attempts %+v, but %+v", expected, tn.result.Attempts)
+	}
+}
+
+func TestBQLoader_AddHandler_RetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	loader, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Handler{
+		Name:        "test-handler",
+		Parser:      CSVParser(),
+		Projector:   func(_ context.Context, r []string) ([]string, error) { return r, nil },
+		Extractor:   NewFileExtractor(t.TempDir()),
+		LoadMethod:  StorageWriteCommitted,
+		RetryPolicy: DefaultRetryPolicy(),
+	}
+
+	if err := loader.AddHandler(context.Background(), h); !errors.Is(err, errCommittedRetry) {
+		t.Errorf("expected %v, but %v", errCommittedRetry, err)
+	}
+}
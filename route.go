@@ -0,0 +1,312 @@
+package bqloader
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"cloud.google.com/go/bigquery"
+	"golang.org/x/xerrors"
+)
+
+var (
+	errNoDestinations       = errors.New("Router requires Destinations")
+	errRouterLoader         = errors.New("Loader can't be specified with Router, specify Loader of each destination instead")
+	errDuplicateDestination = errors.New("duplicate destination name")
+	errNoDestinationTable   = errors.New("destination table is not specified")
+	errNoDestinationLoader  = errors.New("destination loader is not built")
+)
+
+// Router decides the destination of each projected record by the name of Destination,
+// and returns the record to load into it.
+// Returning an error rejects the source record in the same way as projectors.
+type Router func(ctx context.Context, record []string) (string, []string, error)
+
+// Destination is a destination table of records routed by Handler.Router.
+type Destination struct {
+	// Name identifies the destination in Router.
+	Name string
+
+	// Project and Dataset default to those of the handler.
+	Project string
+	Dataset string
+	Table   string
+
+	// Schema declares the schema to create the table if missing and to detect schema drift. Optional.
+	// With TypedProjector, routed records are in the column order of Schema,
+	// which defaults to the handler's Schema.
+	Schema bigquery.Schema
+
+	// Loader loads records into the destination.
+	// Default is the same kind of loader as the handler's one.
+	Loader Loader
+}
+
+// routes holds destinations of a handler across attempts of an event.
+type routes struct {
+	router       Router
+	destinations []*Destination
+	loaders      []Loader
+	index        map[string]int
+
+	// done marks destinations loaded in previous attempts not to load them twice,
+	// and rows holds the number of records loaded into them.
+	mu   sync.Mutex
+	done []bool
+	rows []int
+}
+
+func newRoutes(h *Handler) (*routes, error) {
+	if h.Router == nil {
+		return nil, nil
+	}
+
+	if len(h.Destinations) == 0 {
+		return nil, errNoDestinations
+	}
+
+	index := make(map[string]int, len(h.Destinations))
+	loaders := make([]Loader, len(h.Destinations))
+
+	for i, d := range h.Destinations {
+		loaders[i] = d.Loader
+		if loaders[i] == nil {
+			loaders[i] = h.destinationLoaders[d.Name]
+		}
+		if loaders[i] == nil {
+			return nil, xerrors.Errorf("%w: %s", errNoDestinationLoader, d.Name)
+		}
+		index[d.Name] = i
+	}
+
+	return &routes{
+		router:       h.Router,
+		destinations: h.Destinations,
+		loaders:      loaders,
+		index:        index,
+		done:         make([]bool, len(h.Destinations)),
+		rows:         make([]int, len(h.Destinations)),
+	}, nil
+}
+
+// streaming reports whether any destination loads records while they are being projected.
+func (r *routes) streaming() bool {
+	for _, l := range r.loaders {
+		if _, ok := l.(StreamLoader); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// route routes a record and tags it with the index of the destination.
+func (r *routes) route(ctx context.Context, record []string) ([]string, error) {
+	name, routed, err := r.router(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	i, ok := r.index[name]
+	if !ok {
+		return nil, xerrors.Errorf("unknown destination: %s", name)
+	}
+
+	return append([]string{strconv.Itoa(i)}, routed...), nil
+}
+
+func (r *routes) isDone(i int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.done[i]
+}
+
+func (r *routes) markDone(i, rows int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.done[i] = true
+	r.rows[i] = rows
+}
+
+// loaded returns the number of records loaded into each destination loaded so far.
+func (r *routes) loaded() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rows := make(map[string]int, len(r.destinations))
+	for i, d := range r.destinations {
+		if r.done[i] {
+			rows[d.Name] = r.rows[i]
+		}
+	}
+
+	return rows
+}
+
+// loadRoutes dispatches tagged records to destinations and loads them concurrently.
+// Each destination is loaded independently, so that a failing one doesn't cancel the others.
+// Destinations loaded in previous attempts only drain their records.
+func (p *pipeline) loadRoutes(ctx context.Context, records <-chan []string, res *Result) error {
+	r := p.routes
+	chans := make([]chan []string, len(r.destinations))
+	counts := make([]int, len(r.destinations))
+	attempts := make([]int, len(r.destinations))
+	errs := make([]error, len(r.destinations))
+
+	var wg sync.WaitGroup
+
+	for i, d := range r.destinations {
+		i, d := i, d
+		ch := make(chan []string, p.h.BatchSize)
+		chans[i] = ch
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if r.isDone(i) {
+				errs[i] = drain(ctx, ch)
+				return
+			}
+
+			var err error
+			attempts[i], err = p.loadInto(ctx, r.loaders[i], ch)
+			if err != nil {
+				errs[i] = xerrors.Errorf("failed to load into destination %s: %w", d.Name, err)
+				// Keep receiving records not to block the other destinations.
+				_ = drain(ctx, ch)
+				return
+			}
+
+			// counts are complete since the loader succeeded only after all records were dispatched.
+			r.markDone(i, counts[i])
+		}()
+	}
+
+	err := p.dispatch(ctx, records, chans, counts)
+	wg.Wait()
+
+	for _, a := range attempts {
+		if a > res.Attempts.Load {
+			res.Attempts.Load = a
+		}
+	}
+
+	res.DestinationRows = r.loaded()
+
+	loaded := 0
+	for _, n := range res.DestinationRows {
+		loaded += n
+	}
+	atomic.StoreInt64(&p.st.loadedRows, int64(loaded))
+
+	if err != nil {
+		return err
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dispatch sends tagged records to the channels of their destinations,
+// and closes all of them after all records are dispatched.
+func (p *pipeline) dispatch(ctx context.Context, records <-chan []string, chans []chan []string, counts []int) error {
+	for {
+		var record []string
+		var ok bool
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case record, ok = <-records:
+			if !ok {
+				for _, ch := range chans {
+					close(ch)
+				}
+				return nil
+			}
+		}
+
+		i, err := strconv.Atoi(record[0])
+		if err != nil {
+			return xerrors.Errorf("invalid destination tag: %w", err)
+		}
+		counts[i]++
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case chans[i] <- record[1:]:
+		}
+	}
+}
+
+// buildDestinations validates destinations and builds default loaders of ones without Loader.
+// Built loaders are kept in the handler not to modify Destinations owned by the caller.
+func buildDestinations(ctx context.Context, h *Handler, build func(context.Context, *Handler) (Loader, error)) error {
+	if len(h.Destinations) == 0 {
+		return errNoDestinations
+	}
+
+	if h.Loader != nil {
+		return errRouterLoader
+	}
+
+	names := make(map[string]bool, len(h.Destinations))
+	loaders := map[string]Loader{}
+
+	for _, d := range h.Destinations {
+		if names[d.Name] {
+			return xerrors.Errorf("%w: %s", errDuplicateDestination, d.Name)
+		}
+		names[d.Name] = true
+
+		if d.Loader != nil {
+			continue
+		}
+
+		if d.Table == "" {
+			return xerrors.Errorf("%w: %s", errNoDestinationTable, d.Name)
+		}
+
+		dh := &Handler{
+			Project:          d.Project,
+			Dataset:          d.Dataset,
+			Table:            d.Table,
+			Schema:           d.Schema,
+			TypedProjector:   h.TypedProjector,
+			WriteDisposition: h.WriteDisposition,
+			Partitioner:      h.Partitioner,
+			LoadMethod:       h.LoadMethod,
+		}
+		if dh.Project == "" {
+			dh.Project = h.Project
+		}
+		if dh.Dataset == "" {
+			dh.Dataset = h.Dataset
+		}
+		// Typed records are routed in the column order of the handler's Schema unless declared.
+		if dh.TypedProjector != nil && dh.Schema == nil {
+			dh.Schema = h.Schema
+		}
+
+		l, err := build(ctx, dh)
+		if err != nil {
+			return xerrors.Errorf("failed to build loader of destination %s: %w", d.Name, err)
+		}
+		loaders[d.Name] = l
+	}
+
+	h.destinationLoaders = loaders
+
+	return nil
+}
@@ -0,0 +1,327 @@
+package bqloader
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"golang.org/x/xerrors"
+)
+
+// testRouter routes records by the first column and drops it.
+func testRouter(_ context.Context, r []string) (string, []string, error) {
+	for _, v := range r {
+		if v == "bad" {
+			return "", nil, xerrors.New("bad record")
+		}
+	}
+
+	return r[0], r[1:], nil
+}
+
+func Test_Handler_Router(t *testing.T) {
+	t.Parallel()
+
+	projector := func(_ context.Context, r []string) ([][]string, error) {
+		// A trade with a fee is split into a trade and a cash movement.
+		if r[0] == "trade" && r[2] != "" {
+			return [][]string{{"trade", r[1]}, {"cash", r[2]}}, nil
+		}
+
+		return [][]string{r[:2]}, nil
+	}
+
+	const rawCSV = "trade,100,1\ncash,200,\ntrade,300,\nbad,400,\nunknown,500,\ntrade,600,bad\n"
+
+	trades := &testLoader{}
+	cash := &testStreamLoader{}
+	tn := &resultNotifier{}
+
+	handler := &Handler{
+		Name:           "test-handler",
+		Parser:         CSVParser(),
+		MultiProjector: projector,
+		Router:         testRouter,
+		Destinations: []*Destination{
+			{Name: "trade", Loader: trades},
+			{Name: "cash", Loader: cash},
+		},
+		ErrorPolicy: SkipBadRows(3),
+		Notifier:    tn,
+		BatchSize:   2,
+		Extractor:   newTestExtractor(),
+		semaphore:   make(chan struct{}, 2),
+	}
+	e := Event{Name: "test/name", Bucket: "bucket", source: bytes.NewBufferString(rawCSV)}
+
+	if err := handler.Handle(context.Background(), e); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	assertRecords(t, [][]string{{"100"}, {"300"}}, trades.result)
+	assertRecords(t, [][]string{{"1"}, {"200"}}, cash.result)
+
+	res := tn.result
+
+	if res.DestinationRows["trade"] != 2 || res.DestinationRows["cash"] != 2 {
+		t.Errorf("unexpected destination rows: %v", res.DestinationRows)
+	}
+
+	if res.LoadedRows != 4 {
+		t.Errorf("LoadedRows should be 4, but %d", res.LoadedRows)
+	}
+
+	// The whole source record is rejected even if another record projected from it is routable.
+	lines := []int{3, 4, 5}
+	if len(res.RejectedRows) != len(lines) {
+		t.Fatalf("expected %d rejected rows, but %d", len(lines), len(res.RejectedRows))
+	}
+	for i, r := range res.RejectedRows {
+		if r.Line != lines[i] || !strings.Contains(r.Error, "failed to route") {
+			t.Errorf("unexpected rejected row: %+v", r)
+		}
+	}
+	if !strings.Contains(res.RejectedRows[1].Error, "unknown destination: unknown") {
+		t.Errorf("unexpected error: %s", res.RejectedRows[1].Error)
+	}
+}
+
+func Test_Handler_RouterRetry(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.csv"), []byte("trade,1\ncash,2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	trades := &notifyingStreamLoader{done: make(chan struct{})}
+	cash := &commitFailingLoader{wait: trades.done}
+
+	handler := &Handler{
+		Name:      "test-handler",
+		Parser:    CSVParser(),
+		Projector: func(_ context.Context, r []string) ([]string, error) { return r, nil },
+		Router:    testRouter,
+		Destinations: []*Destination{
+			{Name: "trade", Loader: trades},
+			{Name: "cash", Loader: cash},
+		},
+		RetryPolicy: &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+		BatchSize:   defaultBatchSize,
+		Extractor:   NewFileExtractor(root),
+		semaphore:   make(chan struct{}, 1),
+	}
+
+	if err := handler.Handle(context.Background(), Event{Name: "a.csv"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if cash.calls != 2 {
+		t.Errorf("cash should be loaded twice, but %d", cash.calls)
+	}
+
+	// The trade destination loaded in the first attempt is not loaded again.
+	assertRecords(t, [][]string{{"1"}}, trades.result)
+	assertRecords(t, [][]string{{"2"}}, cash.result)
+}
+
+func Test_Handler_RouterWithoutDestinations(t *testing.T) {
+	t.Parallel()
+
+	handler := &Handler{
+		Name:      "test-handler",
+		Parser:    CSVParser(),
+		Projector: func(_ context.Context, r []string) ([]string, error) { return r, nil },
+		Router:    testRouter,
+		BatchSize: defaultBatchSize,
+		Extractor: newTestExtractor(),
+		semaphore: make(chan struct{}, 1),
+	}
+	e := Event{Name: "test/name", Bucket: "bucket", source: bytes.NewBufferString("trade,1\n")}
+
+	if err := handler.Handle(context.Background(), e); err == nil {
+		t.Errorf("expected error but no error occurred")
+	}
+}
+
+func Test_Handler_RouterIndependentDestinations(t *testing.T) {
+	t.Parallel()
+
+	failed := make(chan struct{})
+	trades := &waitingStreamLoader{wait: failed}
+	cash := &closingFailingLoader{closing: failed}
+	tn := &resultNotifier{}
+
+	handler := &Handler{
+		Name:      "test-handler",
+		Parser:    CSVParser(),
+		Projector: func(_ context.Context, r []string) ([]string, error) { return r, nil },
+		Router:    testRouter,
+		Destinations: []*Destination{
+			{Name: "trade", Loader: trades},
+			{Name: "cash", Loader: cash},
+		},
+		Notifier:  tn,
+		BatchSize: defaultBatchSize,
+		Extractor: newTestExtractor(),
+		semaphore: make(chan struct{}, 1),
+	}
+	e := Event{Name: "test/name", Bucket: "bucket", source: bytes.NewBufferString("trade,1\ncash,2\ntrade,3\n")}
+
+	if err := handler.Handle(context.Background(), e); err == nil {
+		t.Fatalf("expected error but no error occurred")
+	}
+
+	// The trade destination is not canceled by the failing cash destination.
+	assertRecords(t, [][]string{{"1"}, {"3"}}, trades.result)
+
+	res := tn.result
+
+	if len(res.DestinationRows) != 1 || res.DestinationRows["trade"] != 2 {
+		t.Errorf("unexpected destination rows: %v", res.DestinationRows)
+	}
+
+	if res.LoadedRows != 2 {
+		t.Errorf("LoadedRows should be 2, but %d", res.LoadedRows)
+	}
+}
+
+func Test_buildDestinations(t *testing.T) {
+	t.Parallel()
+
+	schema := bigquery.Schema{{Name: "amount", Type: bigquery.IntegerFieldType}}
+	cashSchema := bigquery.Schema{{Name: "cash", Type: bigquery.IntegerFieldType}}
+	custom := &testLoader{}
+
+	handler := &Handler{
+		Name:           "test-handler",
+		Project:        "project",
+		Dataset:        "dataset",
+		Schema:         schema,
+		TypedProjector: func(_ context.Context, r []string) (interface{}, error) { return nil, nil },
+		Router:         testRouter,
+		Destinations: []*Destination{
+			{Name: "trade", Table: "trades"},
+			{Name: "cash", Dataset: "cash", Table: "cash_movements", Schema: cashSchema},
+			{Name: "custom", Loader: custom},
+		},
+	}
+
+	built := map[string]*Handler{}
+	build := func(_ context.Context, h *Handler) (Loader, error) {
+		built[h.Table] = h
+		return &testLoader{}, nil
+	}
+
+	if err := buildDestinations(context.Background(), handler, build); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(built) != 2 {
+		t.Fatalf("expected 2 loaders to be built, but %d", len(built))
+	}
+
+	if h := built["trades"]; h.Project != "project" || h.Dataset != "dataset" || h.TypedProjector == nil ||
+		len(h.Schema) != 1 || h.Schema[0].Name != "amount" {
+		t.Errorf("unexpected handler of trades: %+v", h)
+	}
+
+	if h := built["cash_movements"]; h.Dataset != "cash" || h.TypedProjector == nil ||
+		len(h.Schema) != 1 || h.Schema[0].Name != "cash" {
+		t.Errorf("unexpected handler of cash_movements: %+v", h)
+	}
+
+	// Destinations owned by the caller are kept as is.
+	if handler.Destinations[0].Loader != nil || handler.Destinations[1].Loader != nil ||
+		handler.Destinations[2].Loader != custom {
+		t.Errorf("destinations should not be modified: %+v", handler.Destinations)
+	}
+
+	r, err := newRoutes(handler)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if r.loaders[0] == nil || r.loaders[1] == nil || r.loaders[2] != custom {
+		t.Errorf("unexpected loaders: %v", r.loaders)
+	}
+}
+
+// waitingStreamLoader starts loading after wait is closed.
+type waitingStreamLoader struct {
+	testStreamLoader
+	wait <-chan struct{}
+}
+
+func (l *waitingStreamLoader) LoadStream(ctx context.Context, records <-chan []string) error {
+	<-l.wait
+
+	return l.testStreamLoader.LoadStream(ctx, records)
+}
+
+// closingFailingLoader fails without loading and closes closing.
+type closingFailingLoader struct {
+	testStreamLoader
+	closing chan struct{}
+}
+
+func (l *closingFailingLoader) LoadStream(_ context.Context, _ <-chan []string) error {
+	close(l.closing)
+
+	return xerrors.New("permanent error")
+}
+
+// notifyingStreamLoader closes done after loading once.
+type notifyingStreamLoader struct {
+	testStreamLoader
+	done chan struct{}
+	once sync.Once
+}
+
+func (l *notifyingStreamLoader) LoadStream(ctx context.Context, records <-chan []string) error {
+	err := l.testStreamLoader.LoadStream(ctx, records)
+	l.once.Do(func() { close(l.done) })
+
+	return err
+}
+
+// commitFailingLoader fails once after receiving all records and wait is closed.
+type commitFailingLoader struct {
+	testStreamLoader
+	wait  <-chan struct{}
+	calls int
+}
+
+func (l *commitFailingLoader) LoadStream(ctx context.Context, records <-chan []string) error {
+	l.calls++
+	if l.calls > 1 {
+		return l.testStreamLoader.LoadStream(ctx, records)
+	}
+
+	if err := drain(ctx, records); err != nil {
+		return err
+	}
+	<-l.wait
+
+	return errTransient
+}
+
+func assertRecords(t *testing.T, expected, actual [][]string) {
+	t.Helper()
+
+	if len(expected) != len(actual) {
+		t.Fatalf("expected %d records, but %d: %v", len(expected), len(actual), actual)
+	}
+
+	for i := range expected {
+		if strings.Join(expected[i], ",") != strings.Join(actual[i], ",") {
+			t.Errorf("records[%d] should be %v, but %v", i, expected[i], actual[i])
+		}
+	}
+}
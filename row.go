@@ -0,0 +1,94 @@
+package bqloader
+
+import (
+	"context"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// NamedProjector transforms source records into records for destination
+// accessing columns by names in the header.
+// NamedProjector requires a parser providing a header such as HeaderCSVParser.
+type NamedProjector func(context.Context, *Row) ([]string, error)
+
+// HeaderIterator is a RowIterator which also provides a header.
+type HeaderIterator interface {
+	RowIterator
+
+	// Header returns column names of records.
+	Header() []string
+}
+
+// Row is a source record accessible by column names.
+type Row struct {
+	index   map[string]int
+	columns []string
+	missing []string
+}
+
+// Get returns the value of the column named name.
+// If the header doesn't have the name, Get returns an empty string
+// and the projection of the row fails with an error.
+func (r *Row) Get(name string) string {
+	v, ok := r.Lookup(name)
+	if !ok {
+		r.missing = append(r.missing, name)
+	}
+
+	return v
+}
+
+// Lookup returns the value of the column named name and whether the column exists.
+// A column which exists in the header but not in the record is treated as an empty string.
+func (r *Row) Lookup(name string) (string, bool) {
+	i, ok := r.index[name]
+	if !ok {
+		return "", false
+	}
+
+	if i >= len(r.columns) {
+		return "", true
+	}
+
+	return r.columns[i], true
+}
+
+// Columns returns the raw record.
+func (r *Row) Columns() []string {
+	return r.columns
+}
+
+// Err returns an error if Get was called with names not in the header.
+func (r *Row) Err() error {
+	if len(r.missing) == 0 {
+		return nil
+	}
+
+	return xerrors.Errorf("columns not found in header: %s", strings.Join(r.missing, ", "))
+}
+
+// named adapts NamedProjector to Projector with the header.
+func (p NamedProjector) named(header []string) Projector {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		if _, ok := index[name]; !ok {
+			index[name] = i
+		}
+	}
+
+	return func(ctx context.Context, columns []string) ([]string, error) {
+		r := &Row{index: index, columns: columns}
+
+		record, err := p(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := r.Err(); err != nil {
+			return nil, err
+		}
+
+		return record, nil
+	}
+}
@@ -0,0 +1,99 @@
+package bqloader
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func Test_Handler_NamedProjector(t *testing.T) {
+	t.Parallel()
+
+	projector := func(_ context.Context, r *Row) ([]string, error) {
+		return []string{r.Get("ご利用日"), r.Get("金額"), r.Get("備考")}, nil
+	}
+
+	rawCSV := "\ufeffご利用日,内容,金額,備考\n2022/07/01,foo,100,bar\n2022/07/02,baz,200\n"
+
+	tl := newTestLoader()
+
+	handler := &Handler{
+		Name:           "test-handler",
+		StreamParser:   HeaderCSVParser(),
+		NamedProjector: projector,
+		BatchSize:      defaultBatchSize,
+		Extractor:      newTestExtractor(),
+		Loader:         tl,
+		semaphore:      make(chan struct{}, 1),
+	}
+	e := Event{Name: "test/name", Bucket: "bucket", source: bytes.NewBufferString(rawCSV)}
+
+	if err := handler.Handle(context.Background(), e); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	res := tl.(*testLoader)
+	expected := [][]string{{"2022/07/01", "100", "bar"}, {"2022/07/02", "200", ""}}
+
+	if len(res.result) != len(expected) {
+		t.Fatalf("Size of result records should be %d, but %d", len(expected), len(res.result))
+	}
+
+	for i := range expected {
+		if strings.Join(res.result[i], ",") != strings.Join(expected[i], ",") {
+			t.Errorf("results[%d] should be %v, but %v", i, expected[i], res.result[i])
+		}
+	}
+}
+
+func Test_Handler_NamedProjectorWithMissingColumn(t *testing.T) {
+	t.Parallel()
+
+	projector := func(_ context.Context, r *Row) ([]string, error) {
+		return []string{r.Get("ご利用日"), r.Get("ご利用金額")}, nil
+	}
+
+	handler := &Handler{
+		Name:           "test-handler",
+		StreamParser:   HeaderCSVParser(),
+		NamedProjector: projector,
+		BatchSize:      defaultBatchSize,
+		Extractor:      newTestExtractor(),
+		Loader:         newTestLoader(),
+		semaphore:      make(chan struct{}, 1),
+	}
+	e := Event{Name: "test/name", Bucket: "bucket", source: bytes.NewBufferString("ご利用日,金額\n2022/07/01,100\n")}
+
+	err := handler.Handle(context.Background(), e)
+	if err == nil {
+		t.Fatalf("expected error but no error occurred")
+	}
+
+	if !strings.Contains(err.Error(), "ご利用金額") {
+		t.Errorf("error should contain the missing column name, but %q", err)
+	}
+}
+
+func Test_Handler_NamedProjectorWithoutHeader(t *testing.T) {
+	t.Parallel()
+
+	projector := func(_ context.Context, r *Row) ([]string, error) {
+		return r.Columns(), nil
+	}
+
+	handler := &Handler{
+		Name:           "test-handler",
+		Parser:         CSVParser(),
+		NamedProjector: projector,
+		BatchSize:      defaultBatchSize,
+		Extractor:      newTestExtractor(),
+		Loader:         newTestLoader(),
+		semaphore:      make(chan struct{}, 1),
+	}
+	e := Event{Name: "test/name", Bucket: "bucket", source: bytes.NewBufferString("a,b\n1,2\n")}
+
+	if err := handler.Handle(context.Background(), e); err == nil {
+		t.Fatalf("expected error but no error occurred")
+	}
+}
@@ -0,0 +1,203 @@
+package bqloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/xerrors"
+	"google.golang.org/api/googleapi"
+)
+
+var errSchemaDrift = errors.New("incompatible schema drift")
+
+// tableSpec declares the destination table to create if missing.
+type tableSpec struct {
+	schema       bigquery.Schema
+	partitioning *bigquery.TimePartitioning
+	clustering   *bigquery.Clustering
+}
+
+// tableChecker ensures the table once and caches its metadata for following loads.
+type tableChecker struct {
+	table *bigquery.Table
+	spec  *tableSpec
+
+	mu sync.Mutex
+	md *bigquery.TableMetadata
+}
+
+func (c *tableChecker) ensure(ctx context.Context) (*bigquery.TableMetadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.md != nil {
+		return c.md, nil
+	}
+
+	md, err := ensureTable(ctx, c.table, c.spec)
+	if err != nil {
+		return nil, err
+	}
+	c.md = md
+
+	return md, nil
+}
+
+func newTableSpec(h *Handler) *tableSpec {
+	if h.Schema == nil {
+		return nil
+	}
+
+	return &tableSpec{schema: h.Schema, partitioning: h.TimePartitioning, clustering: h.Clustering}
+}
+
+// ensureTable creates the table if missing, or checks the table against the declared one.
+// Incompatible differences of the schema fail before loading, and compatible ones are logged as warnings
+// as well as differences of time partitioning and clustering, which don't prevent loading.
+func ensureTable(ctx context.Context, t *bigquery.Table, spec *tableSpec) (*bigquery.TableMetadata, error) {
+	md, err := t.Metadata(ctx)
+	if isHTTPStatus(err, http.StatusNotFound) && spec != nil {
+		err = t.Create(ctx, &bigquery.TableMetadata{
+			Schema:           spec.schema,
+			TimePartitioning: spec.partitioning,
+			Clustering:       spec.clustering,
+		})
+		switch {
+		case err == nil:
+			log.Ctx(ctx).Info().Msgf("created table %s", t.FullyQualifiedName())
+		case isHTTPStatus(err, http.StatusConflict):
+			// Another instance created the table concurrently.
+		default:
+			return nil, xerrors.Errorf("failed to create table %s: %w", t.FullyQualifiedName(), err)
+		}
+
+		md, err = t.Metadata(ctx)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get metadata of %s: %w", t.FullyQualifiedName(), err)
+	}
+
+	if spec == nil {
+		return md, nil
+	}
+
+	incompatible, compatible := schemaDrift(spec.schema, md.Schema)
+	compatible = append(compatible, layoutDrift(spec, md)...)
+
+	for _, d := range compatible {
+		log.Ctx(ctx).Warn().Msgf("schema of %s differs: %s", t.FullyQualifiedName(), d)
+	}
+
+	if len(incompatible) > 0 {
+		return nil, xerrors.Errorf("%w in %s: %s", errSchemaDrift, t.FullyQualifiedName(), strings.Join(incompatible, "; "))
+	}
+
+	return md, nil
+}
+
+// schemaDrift compares the declared schema with the actual table schema.
+// Columns are compared by position since CSV records are loaded by position.
+func schemaDrift(declared, actual bigquery.Schema) (incompatible, compatible []string) {
+	for i, f := range declared {
+		if i >= len(actual) {
+			incompatible = append(incompatible, fmt.Sprintf("column %d %s is missing in the table", i, f.Name))
+			continue
+		}
+
+		a := actual[i]
+
+		if !strings.EqualFold(a.Name, f.Name) {
+			incompatible = append(incompatible, fmt.Sprintf("column %d is %s in the table, but %s", i, a.Name, f.Name))
+			continue
+		}
+
+		if a.Type != f.Type {
+			incompatible = append(incompatible, fmt.Sprintf("%s is %s in the table, but %s", f.Name, a.Type, f.Type))
+		}
+
+		if a.Repeated != f.Repeated {
+			incompatible = append(incompatible, fmt.Sprintf("%s is %s in the table, but %s", f.Name, mode(a), mode(f)))
+		} else if a.Required != f.Required {
+			d := fmt.Sprintf("%s is %s in the table, but %s", f.Name, mode(a), mode(f))
+			if a.Required {
+				incompatible = append(incompatible, d)
+			} else {
+				compatible = append(compatible, d)
+			}
+		}
+
+		if a.Description != f.Description {
+			compatible = append(compatible, fmt.Sprintf("description of %s differs", f.Name))
+		}
+	}
+
+	for i := len(declared); i < len(actual); i++ {
+		d := fmt.Sprintf("column %d %s is not declared", i, actual[i].Name)
+		if actual[i].Required {
+			incompatible = append(incompatible, d)
+		} else {
+			compatible = append(compatible, d)
+		}
+	}
+
+	return incompatible, compatible
+}
+
+// layoutDrift compares declared time partitioning and clustering with the actual table.
+func layoutDrift(spec *tableSpec, md *bigquery.TableMetadata) []string {
+	var drift []string
+
+	if p := spec.partitioning; p != nil {
+		if a := md.TimePartitioning; a == nil {
+			drift = append(drift, "table is not partitioned")
+		} else if partitioningType(a) != partitioningType(p) || a.Field != p.Field {
+			drift = append(drift, fmt.Sprintf("table is partitioned by %s of %q, but %s of %q",
+				partitioningType(a), a.Field, partitioningType(p), p.Field))
+		}
+	}
+
+	if c := spec.clustering; c != nil {
+		var actual []string
+		if md.Clustering != nil {
+			actual = md.Clustering.Fields
+		}
+
+		if strings.Join(actual, ",") != strings.Join(c.Fields, ",") {
+			drift = append(drift, fmt.Sprintf("table is clustered by [%s], but [%s]",
+				strings.Join(actual, ", "), strings.Join(c.Fields, ", ")))
+		}
+	}
+
+	return drift
+}
+
+func partitioningType(p *bigquery.TimePartitioning) bigquery.TimePartitioningType {
+	if p.Type == "" {
+		return bigquery.DayPartitioningType
+	}
+
+	return p.Type
+}
+
+func mode(f *bigquery.FieldSchema) string {
+	switch {
+	case f.Repeated:
+		return "REPEATED"
+	case f.Required:
+		return "REQUIRED"
+	default:
+		return "NULLABLE"
+	}
+}
+
+func isHTTPStatus(err error, code int) bool {
+	var gerr *googleapi.Error
+
+	return errors.As(err, &gerr) && gerr.Code == code
+}
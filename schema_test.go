@@ -0,0 +1,268 @@
+package bqloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/rs/zerolog"
+	"google.golang.org/api/option"
+)
+
+func TestSchemaDrift(t *testing.T) {
+	t.Parallel()
+
+	declared := bigquery.Schema{
+		{Name: "date", Type: bigquery.DateFieldType, Required: true},
+		{Name: "amount", Type: bigquery.IntegerFieldType},
+	}
+
+	cases := map[string]struct {
+		actual       bigquery.Schema
+		incompatible int
+		compatible   int
+	}{
+		"same": {declared, 0, 0},
+		"type": {bigquery.Schema{
+			{Name: "date", Type: bigquery.StringFieldType, Required: true},
+			{Name: "amount", Type: bigquery.IntegerFieldType},
+		}, 1, 0},
+		"renamed": {bigquery.Schema{
+			{Name: "date", Type: bigquery.DateFieldType, Required: true},
+			{Name: "price", Type: bigquery.IntegerFieldType},
+		}, 1, 0},
+		"missing column": {declared[:1], 1, 0},
+		"extra nullable column": {bigquery.Schema{
+			declared[0], declared[1], {Name: "memo", Type: bigquery.StringFieldType},
+		}, 0, 1},
+		"extra required column": {bigquery.Schema{
+			declared[0], declared[1], {Name: "memo", Type: bigquery.StringFieldType, Required: true},
+		}, 1, 0},
+		"relaxed": {bigquery.Schema{
+			{Name: "date", Type: bigquery.DateFieldType},
+			{Name: "amount", Type: bigquery.IntegerFieldType},
+		}, 0, 1},
+		"required": {bigquery.Schema{
+			{Name: "date", Type: bigquery.DateFieldType, Required: true},
+			{Name: "amount", Type: bigquery.IntegerFieldType, Required: true},
+		}, 1, 0},
+		"description": {bigquery.Schema{
+			{Name: "DATE", Type: bigquery.DateFieldType, Required: true, Description: "date"},
+			{Name: "amount", Type: bigquery.IntegerFieldType},
+		}, 0, 1},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			incompatible, compatible := schemaDrift(declared, c.actual)
+
+			if len(incompatible) != c.incompatible || len(compatible) != c.compatible {
+				t.Errorf("expected %d incompatible and %d compatible, but %v and %v",
+					c.incompatible, c.compatible, incompatible, compatible)
+			}
+		})
+	}
+}
+
+// fakeTablesServer serves tables.get and tables.insert of BigQuery API.
+type fakeTablesServer struct {
+	mu      sync.Mutex
+	table   map[string]interface{}
+	created bool
+	gets    int
+
+	// conflict makes tables.insert fail as if another instance created the table.
+	conflict bool
+}
+
+func (s *fakeTablesServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/tables/t"):
+		s.gets++
+		if s.table == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": {"code": 404, "message": "Not found: Table p:d.t"}}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(s.table)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/tables"):
+		if err := json.NewDecoder(r.Body).Decode(&s.table); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if s.conflict {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error": {"code": 409, "message": "Already Exists: Table p:d.t"}}`))
+			return
+		}
+		s.created = true
+		_ = json.NewEncoder(w).Encode(s.table)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func newFakeTable(t *testing.T, s *fakeTablesServer) *bigquery.Table {
+	t.Helper()
+
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	c, err := bigquery.NewClient(context.Background(), "p",
+		option.WithEndpoint(srv.URL+"/"), option.WithoutAuthentication(), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c.Dataset("d").Table("t")
+}
+
+func TestEnsureTable(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	spec := &tableSpec{
+		schema: bigquery.Schema{
+			{Name: "date", Type: bigquery.DateFieldType},
+			{Name: "amount", Type: bigquery.IntegerFieldType},
+		},
+		partitioning: &bigquery.TimePartitioning{Type: bigquery.MonthPartitioningType, Field: "date"},
+	}
+
+	s := &fakeTablesServer{}
+	table := newFakeTable(t, s)
+
+	md, err := ensureTable(ctx, table, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !s.created {
+		t.Errorf("table should be created")
+	}
+	if len(md.Schema) != 2 || md.TimePartitioning == nil || md.TimePartitioning.Field != "date" {
+		t.Errorf("unexpected metadata: %+v", md)
+	}
+
+	s.created = false
+	if _, err := ensureTable(ctx, table, spec); err != nil || s.created {
+		t.Errorf("existing table should be used as is: %v", err)
+	}
+
+	spec.schema[1].Type = bigquery.NumericFieldType
+	if _, err := ensureTable(ctx, table, spec); !errors.Is(err, errSchemaDrift) {
+		t.Errorf("expected schema drift error, but %v", err)
+	}
+}
+
+func TestEnsureTable_Conflict(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	ctx := zerolog.New(buf).WithContext(context.Background())
+	spec := &tableSpec{schema: bigquery.Schema{{Name: "date", Type: bigquery.DateFieldType}}}
+
+	s := &fakeTablesServer{conflict: true}
+	table := newFakeTable(t, s)
+
+	// The table is created by another instance and then read.
+	md, err := ensureTable(ctx, table, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if s.created || len(md.Schema) != 1 {
+		t.Errorf("table created by another instance should be used: %+v", md)
+	}
+
+	if strings.Contains(buf.String(), "created table") {
+		t.Errorf("creation should not be logged on conflict: %s", buf.String())
+	}
+}
+
+func TestLayoutDrift(t *testing.T) {
+	t.Parallel()
+
+	spec := &tableSpec{
+		partitioning: &bigquery.TimePartitioning{Field: "date"},
+		clustering:   &bigquery.Clustering{Fields: []string{"account", "category"}},
+	}
+
+	cases := map[string]struct {
+		md    *bigquery.TableMetadata
+		drift int
+	}{
+		"same": {&bigquery.TableMetadata{
+			TimePartitioning: &bigquery.TimePartitioning{Type: bigquery.DayPartitioningType, Field: "date"},
+			Clustering:       &bigquery.Clustering{Fields: []string{"account", "category"}},
+		}, 0},
+		"not partitioned nor clustered": {&bigquery.TableMetadata{}, 2},
+		"partitioning type": {&bigquery.TableMetadata{
+			TimePartitioning: &bigquery.TimePartitioning{Type: bigquery.MonthPartitioningType, Field: "date"},
+			Clustering:       &bigquery.Clustering{Fields: []string{"account", "category"}},
+		}, 1},
+		"partitioning field": {&bigquery.TableMetadata{
+			TimePartitioning: &bigquery.TimePartitioning{},
+			Clustering:       &bigquery.Clustering{Fields: []string{"account", "category"}},
+		}, 1},
+		"clustering order": {&bigquery.TableMetadata{
+			TimePartitioning: &bigquery.TimePartitioning{Field: "date"},
+			Clustering:       &bigquery.Clustering{Fields: []string{"category", "account"}},
+		}, 1},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if drift := layoutDrift(spec, c.md); len(drift) != c.drift {
+				t.Errorf("expected %d drift, but %v", c.drift, drift)
+			}
+		})
+	}
+
+	if drift := layoutDrift(&tableSpec{}, &bigquery.TableMetadata{}); len(drift) != 0 {
+		t.Errorf("undeclared settings should not be compared: %v", drift)
+	}
+}
+
+func TestTableChecker(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	spec := &tableSpec{schema: bigquery.Schema{{Name: "date", Type: bigquery.DateFieldType}}}
+
+	s := &fakeTablesServer{}
+	c := &tableChecker{table: newFakeTable(t, s), spec: spec}
+
+	for i := 0; i < 3; i++ {
+		md, err := c.ensure(ctx)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(md.Schema) != 1 {
+			t.Errorf("unexpected metadata: %+v", md)
+		}
+	}
+
+	// Metadata is fetched before and after creating the table, and not in following checks.
+	if s.gets != 2 {
+		t.Errorf("expected metadata to be fetched 2 times, but %d", s.gets)
+	}
+}
@@ -0,0 +1,77 @@
+package bqloader
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stats collects statistics of processing an event.
+// Counters are updated concurrently by goroutines in a pipeline.
+type stats struct {
+	parsedRows  int64
+	skippedRows int64
+	loadedRows  int64
+	bytesRead   int64
+
+	// parse and project are accumulated time in nanoseconds.
+	parse   int64
+	project int64
+
+	mu    sync.Mutex
+	jobID string
+}
+
+func (s *stats) addParse(started time.Time) {
+	atomic.AddInt64(&s.parse, int64(time.Since(started)))
+}
+
+func (s *stats) addProject(started time.Time) {
+	atomic.AddInt64(&s.project, int64(time.Since(started)))
+}
+
+func (s *stats) setJobID(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobID = id
+}
+
+// fill copies collected statistics into r.
+func (s *stats) fill(r *Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r.ParsedRows = int(atomic.LoadInt64(&s.parsedRows))
+	r.SkippedRows = int(atomic.LoadInt64(&s.skippedRows))
+	r.LoadedRows = int(atomic.LoadInt64(&s.loadedRows))
+	r.BytesRead = atomic.LoadInt64(&s.bytesRead)
+	r.Durations.Parse = time.Duration(atomic.LoadInt64(&s.parse))
+	r.Durations.Project = time.Duration(atomic.LoadInt64(&s.project))
+	r.JobID = s.jobID
+}
+
+// countingReader counts bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	s *stats
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	atomic.AddInt64(&r.s.bytesRead, int64(n))
+
+	return n, err
+}
+
+func withStats(ctx context.Context, s *stats) context.Context {
+	return context.WithValue(ctx, statsKey, s)
+}
+
+func statsFrom(ctx context.Context) (*stats, bool) {
+	s, ok := ctx.Value(statsKey).(*stats)
+
+	return s, ok
+}
@@ -0,0 +1,444 @@
+package bqloader
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
+	"cloud.google.com/go/civil"
+	"golang.org/x/xerrors"
+	"google.golang.org/api/option"
+	storagepb "google.golang.org/genproto/googleapis/cloud/bigquery/storage/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	storageWriteBatchRows  = 500
+	storageWriteBatchBytes = 5 * 1024 * 1024
+)
+
+var errLoadJobMethod = errors.New("LoadJob is not a Storage Write API method")
+
+// LoadMethod selects how the default loader writes records into BigQuery.
+type LoadMethod int
+
+const (
+	// LoadJob loads records with a BigQuery load job per file. This is the default.
+	LoadJob LoadMethod = iota
+
+	// StorageWriteCommitted appends records through a committed stream of BigQuery Storage Write API.
+	// Appended rows become visible immediately, so rows appended before a failure remain in the table.
+	StorageWriteCommitted
+
+	// StorageWritePending appends records through a pending stream of BigQuery Storage Write API
+	// and commits them atomically after all records of a file are appended.
+	// Nothing is committed if the file fails.
+	StorageWritePending
+)
+
+type storageWriteLoader struct {
+	table  *bigquery.Table
+	method LoadMethod
+
+	// opts are options of Storage Write API clients built for each load.
+	opts []option.ClientOption
+
+	// checker caches the table schema to encode rows.
+	checker *tableChecker
+}
+
+// NewStorageWriteLoader builds a StreamLoader using BigQuery Storage Write API.
+// Rows are encoded as protocol buffers derived from the schema of the destination table,
+// which is read at the first load and cached.
+// Values are interpreted in the same way as CSV load jobs and empty values are loaded as NULL.
+func NewStorageWriteLoader(ctx context.Context, project, dataset, table string, m LoadMethod) (StreamLoader, error) {
+	return newStorageWriteLoader(ctx, project, dataset, table, m, nil)
+}
+
+func newStorageWriteLoader(
+	ctx context.Context,
+	project, dataset, table string,
+	m LoadMethod,
+	spec *tableSpec,
+) (*storageWriteLoader, error) {
+	if m == LoadJob {
+		return nil, errLoadJobMethod
+	}
+
+	bq, err := bigquery.NewClient(ctx, project)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to build bigquery client for %s.%s.%s: %w",
+			project, dataset, table, err)
+	}
+
+	t := bq.Dataset(dataset).Table(table)
+
+	return &storageWriteLoader{
+		table:   t,
+		method:  m,
+		checker: &tableChecker{table: t, spec: spec},
+	}, nil
+}
+
+func (l *storageWriteLoader) Load(ctx context.Context, records [][]string) error {
+	ch := make(chan []string, len(records))
+	for _, r := range records {
+		ch <- r
+	}
+	close(ch)
+
+	return l.LoadStream(ctx, ch)
+}
+
+func (l *storageWriteLoader) LoadStream(ctx context.Context, records <-chan []string) error {
+	md, err := l.checker.ensure(ctx)
+	if err != nil {
+		return err
+	}
+
+	enc, err := newRowEncoder(md.Schema)
+	if err != nil {
+		return err
+	}
+
+	// The client is built for each load and closed when the load finishes.
+	client, err := managedwriter.NewClient(ctx, l.table.ProjectID, l.opts...)
+	if err != nil {
+		return xerrors.Errorf("failed to build storage write client for %s: %w", l.table.FullyQualifiedName(), err)
+	}
+	defer client.Close()
+
+	streamType := managedwriter.CommittedStream
+	if l.method == StorageWritePending {
+		streamType = managedwriter.PendingStream
+	}
+
+	ms, err := client.NewManagedStream(ctx,
+		managedwriter.WithType(streamType),
+		managedwriter.WithDestinationTable(
+			managedwriter.TableParentFromParts(l.table.ProjectID, l.table.DatasetID, l.table.TableID)),
+		managedwriter.WithSchemaDescriptor(enc.descriptor),
+	)
+	if err != nil {
+		return xerrors.Errorf("failed to create write stream: %w", err)
+	}
+	defer ms.Close()
+
+	if st, ok := statsFrom(ctx); ok {
+		st.setJobID(ms.StreamName())
+	}
+
+	results, err := l.append(ctx, ms, enc, records)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if _, err := r.GetResult(ctx); err != nil {
+			return xerrors.Errorf("failed to append rows: %w", err)
+		}
+	}
+
+	if l.method == StorageWritePending {
+		return commitStream(ctx, client, ms)
+	}
+
+	return nil
+}
+
+func (l *storageWriteLoader) append(
+	ctx context.Context,
+	ms *managedwriter.ManagedStream,
+	enc *rowEncoder,
+	records <-chan []string,
+) ([]*managedwriter.AppendResult, error) {
+	var (
+		results []*managedwriter.AppendResult
+		rows    [][]byte
+		size    int
+		n       int
+	)
+
+	flush := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+
+		r, err := ms.AppendRows(ctx, rows)
+		if err != nil {
+			return xerrors.Errorf("failed to append rows: %w", err)
+		}
+
+		results = append(results, r)
+		rows = nil
+		size = 0
+
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case r, ok := <-records:
+			if !ok {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				return results, nil
+			}
+
+			b, err := enc.encode(r)
+			if err != nil {
+				return nil, xerrors.Errorf("failed to encode record %d: %w", n, err)
+			}
+			n++
+
+			rows = append(rows, b)
+			size += len(b)
+
+			if len(rows) >= storageWriteBatchRows || size >= storageWriteBatchBytes {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+}
+
+// commitStream finalizes the pending stream and commits it, so that its rows become visible at once.
+func commitStream(ctx context.Context, client *managedwriter.Client, ms *managedwriter.ManagedStream) error {
+	if _, err := ms.Finalize(ctx); err != nil {
+		return xerrors.Errorf("failed to finalize write stream: %w", err)
+	}
+
+	resp, err := client.BatchCommitWriteStreams(ctx, &storagepb.BatchCommitWriteStreamsRequest{
+		Parent:       managedwriter.TableParentFromStreamName(ms.StreamName()),
+		WriteStreams: []string{ms.StreamName()},
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to commit write stream: %w", err)
+	}
+
+	if errs := resp.GetStreamErrors(); len(errs) > 0 {
+		return xerrors.Errorf("failed to commit write stream: %s", errs[0].GetErrorMessage())
+	}
+
+	return nil
+}
+
+// rowEncoder encodes records into serialized protocol buffers of the table schema.
+type rowEncoder struct {
+	schema     bigquery.Schema
+	message    protoreflect.MessageDescriptor
+	descriptor *descriptorpb.DescriptorProto
+}
+
+func newRowEncoder(schema bigquery.Schema) (*rowEncoder, error) {
+	ts, err := adapt.BQSchemaToStorageTableSchema(schema)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to convert table schema: %w", err)
+	}
+
+	d, err := adapt.StorageSchemaToProto2Descriptor(ts, "root")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to build proto descriptor: %w", err)
+	}
+
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, xerrors.New("failed to build proto descriptor: not a message descriptor")
+	}
+
+	dp, err := adapt.NormalizeDescriptor(md)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to normalize proto descriptor: %w", err)
+	}
+
+	return &rowEncoder{schema: schema, message: md, descriptor: dp}, nil
+}
+
+func (e *rowEncoder) encode(record []string) ([]byte, error) {
+	if len(record) > len(e.schema) {
+		return nil, xerrors.Errorf("too many columns: %d columns for %d fields", len(record), len(e.schema))
+	}
+
+	m := dynamicpb.NewMessage(e.message)
+	fields := e.message.Fields()
+
+	for i, s := range record {
+		if s == "" {
+			continue
+		}
+
+		f := e.schema[i]
+
+		v, err := protoValue(f, s)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid value for %s: %w", f.Name, err)
+		}
+
+		m.Set(fields.Get(i), v)
+	}
+
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to marshal row: %w", err)
+	}
+
+	return b, nil
+}
+
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// protoValue converts a CSV value into the proto representation of BigQuery Storage Write API.
+func protoValue(f *bigquery.FieldSchema, s string) (protoreflect.Value, error) {
+	if f.Repeated {
+		return protoreflect.Value{}, xerrors.New("repeated fields are not supported")
+	}
+
+	switch f.Type {
+	case bigquery.StringFieldType, bigquery.GeographyFieldType:
+		return protoreflect.ValueOfString(s), nil
+	case bigquery.BytesFieldType:
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return protoreflect.Value{}, xerrors.Errorf("failed to decode base64: %w", err)
+		}
+		return protoreflect.ValueOfBytes(b), nil
+	case bigquery.IntegerFieldType:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, xerrors.Errorf("failed to parse integer: %w", err)
+		}
+		return protoreflect.ValueOfInt64(n), nil
+	case bigquery.FloatFieldType:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return protoreflect.Value{}, xerrors.Errorf("failed to parse float: %w", err)
+		}
+		return protoreflect.ValueOfFloat64(n), nil
+	case bigquery.BooleanFieldType:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return protoreflect.Value{}, xerrors.Errorf("failed to parse boolean: %w", err)
+		}
+		return protoreflect.ValueOfBool(b), nil
+	case bigquery.DateFieldType:
+		d, err := civil.ParseDate(s)
+		if err != nil {
+			return protoreflect.Value{}, xerrors.Errorf("failed to parse date: %w", err)
+		}
+		return protoreflect.ValueOfInt32(int32(d.DaysSince(civil.Date{Year: 1970, Month: time.January, Day: 1}))), nil
+	case bigquery.TimestampFieldType:
+		t, err := parseTimestamp(s)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfInt64(t.UnixMicro()), nil
+	case bigquery.DateTimeFieldType:
+		dt, err := civil.ParseDateTime(strings.Replace(s, " ", "T", 1))
+		if err != nil {
+			return protoreflect.Value{}, xerrors.Errorf("failed to parse datetime: %w", err)
+		}
+		return protoreflect.ValueOfInt64(packDateTime(dt)), nil
+	case bigquery.TimeFieldType:
+		t, err := civil.ParseTime(s)
+		if err != nil {
+			return protoreflect.Value{}, xerrors.Errorf("failed to parse time: %w", err)
+		}
+		return protoreflect.ValueOfInt64(packTime(t)), nil
+	case bigquery.NumericFieldType:
+		b, err := numericBytes(s, 9)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfBytes(b), nil
+	case bigquery.BigNumericFieldType:
+		b, err := numericBytes(s, 38)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfBytes(b), nil
+	}
+
+	return protoreflect.Value{}, xerrors.Errorf("unsupported field type: %s", f.Type)
+}
+
+func parseTimestamp(s string) (time.Time, error) {
+	for _, l := range timestampLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, xerrors.Errorf("failed to parse timestamp: %s", s)
+}
+
+// packTime encodes TIME in the packed format of BigQuery:
+// hour (5 bits), minute (6 bits), second (6 bits) and microsecond (20 bits).
+func packTime(t civil.Time) int64 {
+	s := int64(t.Hour)<<12 | int64(t.Minute)<<6 | int64(t.Second)
+
+	return s<<20 | int64(t.Nanosecond/1000)
+}
+
+// packDateTime encodes DATETIME in the packed format of BigQuery:
+// year (14 bits), month (4 bits), day (5 bits) followed by the packed time.
+func packDateTime(dt civil.DateTime) int64 {
+	d := int64(dt.Date.Year)<<26 | int64(dt.Date.Month)<<22 | int64(dt.Date.Day)<<17
+
+	return d<<20 | packTime(dt.Time)
+}
+
+// numericBytes encodes NUMERIC or BIGNUMERIC as a little-endian two's complement integer
+// scaled by 10^scale. Extra digits are rounded half away from zero like load jobs.
+func numericBytes(s string, scale int64) ([]byte, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, xerrors.Errorf("failed to parse numeric: %s", s)
+	}
+
+	n := new(big.Int).Mul(r.Num(), new(big.Int).Exp(big.NewInt(10), big.NewInt(scale), nil))
+	q, m := new(big.Int).QuoRem(n, r.Denom(), new(big.Int))
+
+	if m.Abs(m).Lsh(m, 1).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+
+	var b []byte
+	if q.Sign() >= 0 {
+		b = append([]byte{0}, q.Bytes()...)
+	} else {
+		// Two's complement of a negative value in the minimum number of bytes.
+		size := new(big.Int).Not(q).BitLen()/8 + 1
+		b = new(big.Int).Add(q, new(big.Int).Lsh(big.NewInt(1), uint(size*8))).FillBytes(make([]byte, size))
+	}
+
+	// Trim redundant sign bytes of non-negative values.
+	for len(b) > 1 && b[0] == 0 && b[1]&0x80 == 0 {
+		b = b[1:]
+	}
+
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+
+	return b, nil
+}
@@ -0,0 +1,350 @@
+package bqloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"google.golang.org/api/option"
+	storagepb "google.golang.org/genproto/googleapis/cloud/bigquery/storage/v1"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestNumericBytes(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		value    string
+		scale    int64
+		expected []byte
+	}{
+		"zero":            {"0", 0, []byte{0x00}},
+		"positive":        {"127", 0, []byte{0x7f}},
+		"sign byte":       {"128", 0, []byte{0x80, 0x00}},
+		"minus one":       {"-1", 0, []byte{0xff}},
+		"negative":        {"-128", 0, []byte{0x80}},
+		"negative 2bytes": {"-129", 0, []byte{0x7f, 0xff}},
+		"scaled":          {"1.5", 9, []byte{0x00, 0x2f, 0x68, 0x59}},
+		"round up":        {"0.0000000005", 9, []byte{0x01}},
+		"round down":      {"-0.0000000005", 9, []byte{0xff}},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := numericBytes(c.value, c.scale)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if !bytes.Equal(c.expected, actual) {
+				t.Errorf("expected %x, but %x", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPackDateTime(t *testing.T) {
+	t.Parallel()
+
+	dt := civil.DateTime{
+		Date: civil.Date{Year: 2022, Month: 7, Day: 1},
+		Time: civil.Time{Hour: 12, Minute: 34, Second: 56, Nanosecond: 789000},
+	}
+
+	expected := int64(2022)<<46 | int64(7)<<42 | int64(1)<<37 | int64(12)<<32 | int64(34)<<26 | int64(56)<<20 | 789
+	if actual := packDateTime(dt); actual != expected {
+		t.Errorf("expected %d, but %d", expected, actual)
+	}
+}
+
+func TestRowEncoder(t *testing.T) {
+	t.Parallel()
+
+	schema := bigquery.Schema{
+		{Name: "name", Type: bigquery.StringFieldType},
+		{Name: "count", Type: bigquery.IntegerFieldType},
+		{Name: "date", Type: bigquery.DateFieldType},
+		{Name: "at", Type: bigquery.TimestampFieldType},
+		{Name: "ok", Type: bigquery.BooleanFieldType},
+	}
+
+	enc, err := newRowEncoder(schema)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	b, err := enc.encode([]string{"foo", "", "1970-01-03", "1970-01-01 00:00:01+09:00", "true"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	m := dynamicpb.NewMessage(enc.message)
+	if err := proto.Unmarshal(b, m); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	fields := enc.message.Fields()
+
+	if v := m.Get(fields.Get(0)).String(); v != "foo" {
+		t.Errorf("name should be foo, but %s", v)
+	}
+	if m.Has(fields.Get(1)) {
+		t.Errorf("empty count should be NULL")
+	}
+	if v := m.Get(fields.Get(2)).Int(); v != 2 {
+		t.Errorf("date should be 2, but %d", v)
+	}
+	if v := m.Get(fields.Get(3)).Int(); v != (1-9*60*60)*1000000 {
+		t.Errorf("unexpected timestamp: %d", v)
+	}
+	if !m.Get(fields.Get(4)).Bool() {
+		t.Errorf("ok should be true")
+	}
+
+	if _, err := enc.encode([]string{"foo", "bar"}); err == nil {
+		t.Errorf("expected error for invalid integer")
+	}
+	if _, err := enc.encode(make([]string, 6)); err == nil {
+		t.Errorf("expected error for too many columns")
+	}
+}
+
+// fakeWriteServer is a fake BigQueryWrite server recording appended rows and committed streams.
+type fakeWriteServer struct {
+	storagepb.UnimplementedBigQueryWriteServer
+
+	mu        sync.Mutex
+	types     []storagepb.WriteStream_Type
+	rows      int
+	finalized []string
+	committed []string
+
+	// failAppend makes appends fail with an error response.
+	failAppend bool
+}
+
+func (s *fakeWriteServer) CreateWriteStream(
+	_ context.Context,
+	req *storagepb.CreateWriteStreamRequest,
+) (*storagepb.WriteStream, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.types = append(s.types, req.GetWriteStream().GetType())
+
+	return &storagepb.WriteStream{
+		Name: fmt.Sprintf("%s/streams/s%d", req.GetParent(), len(s.types)),
+		Type: req.GetWriteStream().GetType(),
+	}, nil
+}
+
+func (s *fakeWriteServer) AppendRows(stream storagepb.BigQueryWrite_AppendRowsServer) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		fail := s.failAppend
+		if !fail {
+			s.rows += len(req.GetProtoRows().GetRows().GetSerializedRows())
+		}
+		s.mu.Unlock()
+
+		resp := &storagepb.AppendRowsResponse{Response: &storagepb.AppendRowsResponse_AppendResult_{
+			AppendResult: &storagepb.AppendRowsResponse_AppendResult{},
+		}}
+		if fail {
+			resp.Response = &storagepb.AppendRowsResponse_Error{
+				Error: &statuspb.Status{Code: int32(codes.InvalidArgument), Message: "invalid rows"},
+			}
+		}
+
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *fakeWriteServer) FinalizeWriteStream(
+	_ context.Context,
+	req *storagepb.FinalizeWriteStreamRequest,
+) (*storagepb.FinalizeWriteStreamResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.finalized = append(s.finalized, req.GetName())
+
+	return &storagepb.FinalizeWriteStreamResponse{RowCount: int64(s.rows)}, nil
+}
+
+func (s *fakeWriteServer) BatchCommitWriteStreams(
+	_ context.Context,
+	req *storagepb.BatchCommitWriteStreamsRequest,
+) (*storagepb.BatchCommitWriteStreamsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.committed = append(s.committed, req.GetWriteStreams()...)
+
+	return &storagepb.BatchCommitWriteStreamsResponse{CommitTime: timestamppb.Now()}, nil
+}
+
+func newFakeStorageWriteLoader(t *testing.T, s *fakeWriteServer, m LoadMethod) *storageWriteLoader {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer()
+	storagepb.RegisterBigQueryWriteServer(srv, s)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	table := newFakeTable(t, &fakeTablesServer{table: map[string]interface{}{
+		"schema": map[string]interface{}{"fields": []map[string]interface{}{
+			{"name": "date", "type": "DATE"},
+			{"name": "amount", "type": "INTEGER"},
+		}},
+	}})
+
+	return &storageWriteLoader{
+		table:  table,
+		method: m,
+		opts: []option.ClientOption{
+			option.WithEndpoint(lis.Addr().String()),
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		},
+		checker: &tableChecker{table: table},
+	}
+}
+
+func TestStorageWriteLoader_LoadStream(t *testing.T) {
+	t.Parallel()
+
+	records := [][]string{{"2022-07-01", "100"}, {"2022-07-02", ""}, {"2022-07-03", "-300"}}
+
+	t.Run("pending", func(t *testing.T) {
+		t.Parallel()
+
+		s := &fakeWriteServer{}
+		l := newFakeStorageWriteLoader(t, s, StorageWritePending)
+
+		if err := l.Load(context.Background(), records); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(s.types) != 1 || s.types[0] != storagepb.WriteStream_PENDING {
+			t.Errorf("unexpected stream types: %v", s.types)
+		}
+
+		if s.rows != 3 {
+			t.Errorf("expected 3 rows to be appended, but %d", s.rows)
+		}
+
+		// The stream is finalized and committed exactly once.
+		stream := "projects/p/datasets/d/tables/t/streams/s1"
+		if len(s.finalized) != 1 || s.finalized[0] != stream || len(s.committed) != 1 || s.committed[0] != stream {
+			t.Errorf("unexpected finalized %v and committed %v", s.finalized, s.committed)
+		}
+	})
+
+	t.Run("committed", func(t *testing.T) {
+		t.Parallel()
+
+		s := &fakeWriteServer{}
+		l := newFakeStorageWriteLoader(t, s, StorageWriteCommitted)
+
+		if err := l.Load(context.Background(), records); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(s.types) != 1 || s.types[0] != storagepb.WriteStream_COMMITTED || s.rows != 3 {
+			t.Errorf("unexpected stream types %v and rows %d", s.types, s.rows)
+		}
+
+		if len(s.finalized) != 0 || len(s.committed) != 0 {
+			t.Errorf("committed stream should not be committed again: %v, %v", s.finalized, s.committed)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		t.Parallel()
+
+		s := &fakeWriteServer{}
+		l := newFakeStorageWriteLoader(t, s, StorageWritePending)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := make(chan []string, 1)
+		ch <- records[0]
+
+		errCh := make(chan error, 1)
+		go func() { errCh <- l.LoadStream(ctx, ch) }()
+
+		// The pipeline fails without closing the channel.
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+
+		if err := <-errCh; err == nil {
+			t.Fatalf("expected error but no error occurred")
+		}
+
+		if len(s.finalized) != 0 || len(s.committed) != 0 {
+			t.Errorf("nothing should be committed: %v, %v", s.finalized, s.committed)
+		}
+	})
+
+	t.Run("append error", func(t *testing.T) {
+		t.Parallel()
+
+		s := &fakeWriteServer{failAppend: true}
+		l := newFakeStorageWriteLoader(t, s, StorageWritePending)
+
+		if err := l.Load(context.Background(), records); err == nil {
+			t.Fatalf("expected error but no error occurred")
+		}
+
+		if len(s.finalized) != 0 || len(s.committed) != 0 {
+			t.Errorf("nothing should be committed: %v, %v", s.finalized, s.committed)
+		}
+	})
+
+	t.Run("invalid record", func(t *testing.T) {
+		t.Parallel()
+
+		s := &fakeWriteServer{}
+		l := newFakeStorageWriteLoader(t, s, StorageWritePending)
+
+		if err := l.Load(context.Background(), [][]string{records[0], {"invalid", "1"}}); err == nil {
+			t.Fatalf("expected error but no error occurred")
+		}
+
+		if len(s.finalized) != 0 || len(s.committed) != 0 {
+			t.Errorf("nothing should be committed: %v, %v", s.finalized, s.committed)
+		}
+	})
+}
@@ -0,0 +1,445 @@
+package bqloader
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"golang.org/x/xerrors"
+)
+
+var (
+	errNoSchema          = errors.New("TypedProjector requires Schema")
+	errTypedLineNumber   = errors.New("AppendLineNumber is not supported with TypedProjector")
+	errNumericOutOfRange = errors.New("out of range")
+
+	// maxNumeric is the maximum absolute value of NUMERIC.
+	maxNumeric, _ = new(big.Rat).SetString("99999999999999999999999999999.999999999")
+)
+
+// TypedProjector transforms source records into typed rows for destination.
+// A row is a struct or a pointer to a struct, a map[string]bigquery.Value or a bigquery.ValueSaver.
+// Struct fields are mapped to columns by bigquery struct tags or their names,
+// and fields tagged with `bigquery:"-"` are ignored. Returning nil skips the record.
+//
+// Rows are validated against Handler.Schema in Go, so that malformed values, numerics out of range
+// and NULL in REQUIRED columns fail the row with its line number and are handled by ErrorPolicy.
+// Empty strings are loaded as NULL in the same way as with Projector.
+//
+// Loader receives rows as records in the column order of Schema.
+// The default loader loads them as JSON with a load job, or as protocol buffers with Storage Write API.
+type TypedProjector func(context.Context, []string) (interface{}, error)
+
+// typed adapts TypedProjector to Projector with the schema.
+func (p TypedProjector) typed(schema bigquery.Schema) Projector {
+	return func(ctx context.Context, columns []string) ([]string, error) {
+		v, err := p(ctx, columns)
+		if err != nil {
+			return nil, err
+		}
+
+		return encodeRow(schema, v)
+	}
+}
+
+// encodeRow validates a typed row and encodes it into a record in the column order of the schema.
+func encodeRow(schema bigquery.Schema, v interface{}) ([]string, error) {
+	values, err := rowValues(v)
+	if err != nil || values == nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(schema))
+	for i, f := range schema {
+		index[strings.ToLower(f.Name)] = i
+	}
+
+	record := make([]string, len(schema))
+	found := make([]bool, len(schema))
+
+	for name, value := range values {
+		i, ok := index[strings.ToLower(name)]
+		if !ok {
+			return nil, xerrors.Errorf("column %s is not in the schema", name)
+		}
+
+		f := schema[i]
+
+		s, err := encodeValue(f, value)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid value for %s: %w", f.Name, err)
+		}
+
+		record[i] = s
+		found[i] = true
+	}
+
+	for i, f := range schema {
+		if f.Required && record[i] == "" {
+			if found[i] {
+				return nil, xerrors.Errorf("%s is required, but empty", f.Name)
+			}
+			return nil, xerrors.Errorf("%s is required, but missing", f.Name)
+		}
+	}
+
+	return record, nil
+}
+
+// rowValues converts a typed row into values keyed by column names.
+// It returns nil if v is nil.
+func rowValues(v interface{}) (map[string]bigquery.Value, error) {
+	switch row := v.(type) {
+	case nil:
+		return nil, nil
+	case map[string]bigquery.Value:
+		return row, nil
+	case map[string]interface{}:
+		values := make(map[string]bigquery.Value, len(row))
+		for k, v := range row {
+			values[k] = v
+		}
+		return values, nil
+	case bigquery.ValueSaver:
+		values, _, err := row.Save()
+		if err != nil {
+			return nil, xerrors.Errorf("failed to save row: %w", err)
+		}
+		return values, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return nil, xerrors.Errorf("unsupported row type %T", v)
+	}
+
+	values := map[string]bigquery.Value{}
+	structValues(rv, values)
+
+	return values, nil
+}
+
+func structValues(rv reflect.Value, values map[string]bigquery.Value) {
+	t := rv.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		name := strings.Split(sf.Tag.Get("bigquery"), ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		fv := rv.Field(i)
+
+		// Fields of embedded structs are promoted like bigquery.InferSchema.
+		if sf.Anonymous && name == "" {
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				structValues(fv, values)
+				continue
+			}
+		}
+
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+
+		values[name] = fv.Interface()
+	}
+}
+
+// encodeValue validates a value for the field and encodes it as a record value.
+// NULL is encoded as an empty string.
+func encodeValue(f *bigquery.FieldSchema, v interface{}) (string, error) {
+	if f.Repeated || f.Type == bigquery.RecordFieldType {
+		return "", xerrors.New("repeated and record fields are not supported")
+	}
+
+	v, ok := nullableValue(v)
+	if !ok {
+		return "", nil
+	}
+
+	if s, ok := v.(string); ok {
+		return encodeString(f, s)
+	}
+
+	switch f.Type {
+	case bigquery.StringFieldType, bigquery.GeographyFieldType:
+		// Only strings are accepted.
+	case bigquery.BytesFieldType:
+		if b, ok := v.([]byte); ok {
+			return base64.StdEncoding.EncodeToString(b), nil
+		}
+	case bigquery.IntegerFieldType:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return strconv.FormatInt(rv.Int(), 10), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if rv.Uint() > math.MaxInt64 {
+				return "", xerrors.Errorf("%d is %w", rv.Uint(), errNumericOutOfRange)
+			}
+			return strconv.FormatUint(rv.Uint(), 10), nil
+		}
+	case bigquery.FloatFieldType:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return strconv.FormatFloat(rv.Float(), 'g', -1, 64), nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return strconv.FormatInt(rv.Int(), 10), nil
+		}
+	case bigquery.BooleanFieldType:
+		if b, ok := v.(bool); ok {
+			return strconv.FormatBool(b), nil
+		}
+	case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+		if r, ok := numericRat(v); ok {
+			return encodeNumeric(f, r)
+		}
+	case bigquery.DateFieldType:
+		switch d := v.(type) {
+		case civil.Date:
+			return d.String(), nil
+		case time.Time:
+			return civil.DateOf(d).String(), nil
+		}
+	case bigquery.DateTimeFieldType:
+		switch dt := v.(type) {
+		case civil.DateTime:
+			return bigquery.CivilDateTimeString(dt), nil
+		case time.Time:
+			return bigquery.CivilDateTimeString(civil.DateTimeOf(dt)), nil
+		}
+	case bigquery.TimeFieldType:
+		if t, ok := v.(civil.Time); ok {
+			return bigquery.CivilTimeString(t), nil
+		}
+	case bigquery.TimestampFieldType:
+		if t, ok := v.(time.Time); ok {
+			return t.UTC().Format("2006-01-02 15:04:05.999999-07:00"), nil
+		}
+	default:
+		return "", xerrors.Errorf("unsupported field type: %s", f.Type)
+	}
+
+	return "", xerrors.Errorf("cannot use %T as %s", v, f.Type)
+}
+
+// encodeString validates a string value in the same formats as CSV and normalizes it.
+func encodeString(f *bigquery.FieldSchema, s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+
+	switch f.Type {
+	case bigquery.StringFieldType, bigquery.GeographyFieldType:
+		return s, nil
+	case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return "", xerrors.Errorf("failed to parse numeric: %s", s)
+		}
+		return encodeNumeric(f, r)
+	}
+
+	if _, err := protoValue(f, s); err != nil {
+		return "", err
+	}
+
+	// Normalize values to be valid as JSON literals.
+	switch f.Type {
+	case bigquery.IntegerFieldType:
+		n, _ := strconv.ParseInt(s, 10, 64)
+		return strconv.FormatInt(n, 10), nil
+	case bigquery.BooleanFieldType:
+		b, _ := strconv.ParseBool(s)
+		return strconv.FormatBool(b), nil
+	}
+
+	return s, nil
+}
+
+func encodeNumeric(f *bigquery.FieldSchema, r *big.Rat) (string, error) {
+	if f.Type == bigquery.NumericFieldType {
+		s := bigquery.NumericString(r)
+		if rounded, _ := new(big.Rat).SetString(s); rounded.Abs(rounded).Cmp(maxNumeric) > 0 {
+			return "", xerrors.Errorf("%s is %w of NUMERIC", s, errNumericOutOfRange)
+		}
+		return s, nil
+	}
+
+	s := bigquery.BigNumericString(r)
+
+	// BIGNUMERIC is a 256-bit integer scaled by 10^38.
+	b, err := numericBytes(s, 38)
+	if err != nil {
+		return "", err
+	}
+	if len(b) > 32 {
+		return "", xerrors.Errorf("%s is %w of BIGNUMERIC", s, errNumericOutOfRange)
+	}
+
+	return s, nil
+}
+
+func numericRat(v interface{}) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case *big.Rat:
+		return n, true
+	case big.Rat:
+		return &n, true
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(rv.Uint())), true
+	case reflect.Float32, reflect.Float64:
+		// SetFloat64 returns nil for NaN and infinities.
+		r := new(big.Rat).SetFloat64(rv.Float())
+		return r, r != nil
+	}
+
+	return nil, false
+}
+
+// nullableValue unwraps pointers and bigquery.Null* types, and returns false for NULL.
+func nullableValue(v interface{}) (interface{}, bool) {
+	switch n := v.(type) {
+	case nil:
+		return nil, false
+	case *big.Rat:
+		return n, n != nil
+	case bigquery.NullString:
+		return n.StringVal, n.Valid
+	case bigquery.NullGeography:
+		return n.GeographyVal, n.Valid
+	case bigquery.NullInt64:
+		return n.Int64, n.Valid
+	case bigquery.NullFloat64:
+		return n.Float64, n.Valid
+	case bigquery.NullBool:
+		return n.Bool, n.Valid
+	case bigquery.NullTimestamp:
+		return n.Timestamp, n.Valid
+	case bigquery.NullDate:
+		return n.Date, n.Valid
+	case bigquery.NullTime:
+		return n.Time, n.Valid
+	case bigquery.NullDateTime:
+		return n.DateTime, n.Valid
+	}
+
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, false
+		}
+		return nullableValue(rv.Elem().Interface())
+	}
+
+	return v, true
+}
+
+// jsonRecordWriter writes records as JSON lines keyed by column names of the schema.
+type jsonRecordWriter struct {
+	w      *bufio.Writer
+	schema bigquery.Schema
+}
+
+func newJSONRecordWriter(w io.Writer, schema bigquery.Schema) *jsonRecordWriter {
+	return &jsonRecordWriter{w: bufio.NewWriter(w), schema: schema}
+}
+
+func (w *jsonRecordWriter) Write(record []string) error {
+	if len(record) > len(w.schema) {
+		return xerrors.Errorf("too many columns: %d columns for %d fields", len(record), len(w.schema))
+	}
+
+	buf := []byte{'{'}
+
+	for i, s := range record {
+		if s == "" {
+			continue
+		}
+
+		f := w.schema[i]
+
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+
+		name, err := json.Marshal(f.Name)
+		if err != nil {
+			return xerrors.Errorf("failed to encode column name %s: %w", f.Name, err)
+		}
+		buf = append(buf, name...)
+		buf = append(buf, ':')
+
+		if jsonLiteral(f.Type) && json.Valid([]byte(s)) {
+			buf = append(buf, s...)
+			continue
+		}
+
+		value, err := json.Marshal(s)
+		if err != nil {
+			return xerrors.Errorf("failed to encode value of %s: %w", f.Name, err)
+		}
+		buf = append(buf, value...)
+	}
+
+	buf = append(buf, '}', '\n')
+
+	if _, err := w.w.Write(buf); err != nil {
+		return xerrors.Errorf("failed to write json: %w", err)
+	}
+
+	return nil
+}
+
+func (w *jsonRecordWriter) Flush() error {
+	if err := w.w.Flush(); err != nil {
+		return xerrors.Errorf("failed to write json: %w", err)
+	}
+
+	return nil
+}
+
+// jsonLiteral reports whether values of the type are written as JSON literals instead of strings.
+func jsonLiteral(t bigquery.FieldType) bool {
+	switch t {
+	case bigquery.IntegerFieldType, bigquery.FloatFieldType, bigquery.NumericFieldType,
+		bigquery.BigNumericFieldType, bigquery.BooleanFieldType:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,262 @@
+package bqloader
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+)
+
+var typedTestSchema = bigquery.Schema{
+	{Name: "date", Type: bigquery.DateFieldType, Required: true},
+	{Name: "description", Type: bigquery.StringFieldType},
+	{Name: "amount", Type: bigquery.NumericFieldType},
+	{Name: "count", Type: bigquery.IntegerFieldType},
+}
+
+type typedTestRow struct {
+	Date        civil.Date `bigquery:"date"`
+	Description string
+	Amount      *big.Rat           `bigquery:"amount"`
+	Count       bigquery.NullInt64 `bigquery:"count"`
+	Ignored     string             `bigquery:"-"`
+}
+
+func TestEncodeValue(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2022, 7, 1, 12, 34, 56, 789000, time.FixedZone("JST", 9*60*60))
+	big1, _ := new(big.Rat).SetString("99999999999999999999999999999.9999999994")
+	big2, _ := new(big.Rat).SetString("99999999999999999999999999999.9999999995")
+	n := int64(42)
+	var nilInt *int64
+
+	cases := map[string]struct {
+		typ      bigquery.FieldType
+		value    interface{}
+		expected string
+		err      bool
+	}{
+		"string":                {bigquery.StringFieldType, "foo", "foo", false},
+		"int as string":         {bigquery.StringFieldType, 1, "", true},
+		"null string":           {bigquery.StringFieldType, bigquery.NullString{}, "", false},
+		"integer":               {bigquery.IntegerFieldType, int32(-1), "-1", false},
+		"integer pointer":       {bigquery.IntegerFieldType, &n, "42", false},
+		"nil pointer":           {bigquery.IntegerFieldType, nilInt, "", false},
+		"integer string":        {bigquery.IntegerFieldType, "+01", "1", false},
+		"invalid integer":       {bigquery.IntegerFieldType, "1.5", "", true},
+		"uint overflow":         {bigquery.IntegerFieldType, uint64(1 << 63), "", true},
+		"float":                 {bigquery.FloatFieldType, 1.5, "1.5", false},
+		"boolean string":        {bigquery.BooleanFieldType, "TRUE", "true", false},
+		"numeric":               {bigquery.NumericFieldType, big.NewRat(3, 2), "1.500000000", false},
+		"numeric float":         {bigquery.NumericFieldType, 0.25, "0.250000000", false},
+		"numeric string":        {bigquery.NumericFieldType, "-12.3", "-12.300000000", false},
+		"numeric max":           {bigquery.NumericFieldType, big1, "99999999999999999999999999999.999999999", false},
+		"numeric out of range":  {bigquery.NumericFieldType, big2, "", true},
+		"invalid numeric":       {bigquery.NumericFieldType, "1,000", "", true},
+		"bignumeric":            {bigquery.BigNumericFieldType, big2, "99999999999999999999999999999.99999999950000000000000000000000000000", false},
+		"date":                  {bigquery.DateFieldType, civil.Date{Year: 2022, Month: 7, Day: 1}, "2022-07-01", false},
+		"date of time":          {bigquery.DateFieldType, ts, "2022-07-01", false},
+		"invalid date":          {bigquery.DateFieldType, "2022/07/01", "", true},
+		"null date":             {bigquery.DateFieldType, bigquery.NullDate{}, "", false},
+		"datetime":              {bigquery.DateTimeFieldType, civil.DateTimeOf(ts), "2022-07-01 12:34:56.000789", false},
+		"time":                  {bigquery.TimeFieldType, civil.TimeOf(ts), "12:34:56.000789", false},
+		"timestamp":             {bigquery.TimestampFieldType, ts, "2022-07-01 03:34:56.000789+00:00", false},
+		"bytes":                 {bigquery.BytesFieldType, []byte("foo"), "Zm9v", false},
+		"timestamp as date str": {bigquery.TimestampFieldType, "2022-07-01", "2022-07-01", false},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := encodeValue(&bigquery.FieldSchema{Name: "f", Type: c.typ}, c.value)
+			if c.err {
+				if err == nil {
+					t.Errorf("expected error but no error occurred: %q", actual)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if actual != c.expected {
+				t.Errorf("expected %q, but %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEncodeRow(t *testing.T) {
+	t.Parallel()
+
+	date := civil.Date{Year: 2022, Month: 7, Day: 1}
+
+	cases := map[string]struct {
+		row      interface{}
+		expected []string
+		err      string
+	}{
+		"struct": {
+			row:      typedTestRow{Date: date, Description: "foo", Amount: big.NewRat(1, 4), Ignored: "x"},
+			expected: []string{"2022-07-01", "foo", "0.250000000", ""},
+		},
+		"struct pointer": {
+			row:      &typedTestRow{Date: date, Count: bigquery.NullInt64{Int64: 3, Valid: true}},
+			expected: []string{"2022-07-01", "", "", "3"},
+		},
+		"map": {
+			row:      map[string]bigquery.Value{"DATE": "2022-07-01", "amount": 100},
+			expected: []string{"2022-07-01", "", "100.000000000", ""},
+		},
+		"nil": {
+			row:      (*typedTestRow)(nil),
+			expected: nil,
+		},
+		"missing required": {
+			row: map[string]interface{}{"description": "foo"},
+			err: "date is required",
+		},
+		"unknown column": {
+			row: map[string]interface{}{"date": "2022-07-01", "memo": "foo"},
+			err: "memo is not in the schema",
+		},
+		"invalid value": {
+			row: map[string]interface{}{"date": "2022-07-01", "count": "many"},
+			err: "invalid value for count",
+		},
+		"unsupported type": {
+			row: []string{"2022-07-01"},
+			err: "unsupported row type",
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := encodeRow(typedTestSchema, c.row)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Errorf("error should contain %q, but %v", c.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if strings.Join(actual, ",") != strings.Join(c.expected, ",") || len(actual) != len(c.expected) {
+				t.Errorf("expected %q, but %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestJSONRecordWriter(t *testing.T) {
+	t.Parallel()
+
+	schema := bigquery.Schema{
+		{Name: "name", Type: bigquery.StringFieldType},
+		{Name: "amount", Type: bigquery.NumericFieldType},
+		{Name: "ok", Type: bigquery.BooleanFieldType},
+		{Name: "score", Type: bigquery.FloatFieldType},
+	}
+
+	buf := &bytes.Buffer{}
+	w := newJSONRecordWriter(buf, schema)
+
+	for _, r := range [][]string{{`"foo"`, "1.5", "true", "NaN"}, {"", "", "", ""}} {
+		if err := w.Write(r); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "{\"name\":\"\\\"foo\\\"\",\"amount\":1.5,\"ok\":true,\"score\":\"NaN\"}\n{}\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, but %q", expected, buf.String())
+	}
+
+	if err := w.Write(make([]string, 5)); err == nil {
+		t.Errorf("expected error for too many columns")
+	}
+}
+
+func Test_Handler_TypedProjector(t *testing.T) {
+	t.Parallel()
+
+	projector := func(_ context.Context, r []string) (interface{}, error) {
+		if r[0] == "" {
+			return nil, nil
+		}
+
+		return map[string]bigquery.Value{"date": r[0], "description": r[1], "amount": r[2]}, nil
+	}
+
+	const rawCSV = "2022-07-01,foo,100\n,skipped,0\n2022/07/02,bar,200\n2022-07-03,baz,300\n"
+
+	tl := &testLoader{}
+
+	handler := &Handler{
+		Name:           "test-handler",
+		Parser:         CSVParser(),
+		TypedProjector: projector,
+		Schema:         typedTestSchema,
+		ErrorPolicy:    SkipBadRows(1),
+		BatchSize:      defaultBatchSize,
+		Extractor:      newTestExtractor(),
+		Loader:         tl,
+		semaphore:      make(chan struct{}, 1),
+	}
+	e := Event{Name: "test/name", Bucket: "bucket", source: bytes.NewBufferString(rawCSV)}
+
+	rn := &resultNotifier{}
+	handler.Notifier = rn
+
+	if err := handler.Handle(context.Background(), e); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := [][]string{
+		{"2022-07-01", "foo", "100.000000000", ""},
+		{"2022-07-03", "baz", "300.000000000", ""},
+	}
+
+	if len(tl.result) != len(expected) {
+		t.Fatalf("Size of result records should be %d, but %d", len(expected), len(tl.result))
+	}
+
+	for i := range expected {
+		if strings.Join(tl.result[i], ",") != strings.Join(expected[i], ",") {
+			t.Errorf("results[%d] should be %v, but %v", i, expected[i], tl.result[i])
+		}
+	}
+
+	rejected := rn.result.RejectedRows
+	if len(rejected) != 1 {
+		t.Fatalf("expected 1 rejected row, but %d", len(rejected))
+	}
+	if rejected[0].Line != 2 || !strings.Contains(rejected[0].Error, "invalid value for date") {
+		t.Errorf("unexpected rejected row: %+v", rejected[0])
+	}
+
+	handler.Schema = nil
+	e.source = bytes.NewBufferString(rawCSV)
+
+	if err := handler.Handle(context.Background(), e); err == nil {
+		t.Errorf("expected error without Schema")
+	}
+}
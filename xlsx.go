@@ -0,0 +1,602 @@
+package bqloader
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/xerrors"
+)
+
+var errXLSXNoSheet = errors.New("no sheet found")
+
+// XLSXOption configures XLSXParser.
+type XLSXOption func(*xlsxConfig)
+
+type xlsxConfig struct {
+	sheetIndex int
+	sheetName  string
+	headerRow  int
+	cellRange  string
+	filter     func([]string) bool
+	isoDates   bool
+}
+
+// XLSXSheetIndex selects the sheet to parse by the 0-based index. Default is the first sheet.
+func XLSXSheetIndex(i int) XLSXOption {
+	return func(c *xlsxConfig) {
+		c.sheetIndex = i
+		c.sheetName = ""
+	}
+}
+
+// XLSXSheetName selects the sheet to parse by the name.
+func XLSXSheetName(name string) XLSXOption {
+	return func(c *xlsxConfig) {
+		c.sheetName = name
+	}
+}
+
+// XLSXHeaderRow treats the n-th row (1-based) as the header to access columns by names in NamedProjector.
+// Rows above the header are skipped, and the header is not counted in Handler.SkipLeadingRows.
+func XLSXHeaderRow(n int) XLSXOption {
+	return func(c *xlsxConfig) {
+		c.headerRow = n
+	}
+}
+
+// XLSXRange limits cells to parse to a range like "A2:F100".
+// Records have the same number of columns as the range.
+func XLSXRange(ref string) XLSXOption {
+	return func(c *xlsxConfig) {
+		c.cellRange = ref
+	}
+}
+
+// XLSXRowFilter keeps only records for which f returns true.
+func XLSXRowFilter(f func(record []string) bool) XLSXOption {
+	return func(c *xlsxConfig) {
+		c.filter = f
+	}
+}
+
+// XLSXISODates formats date cells as ISO 8601 strings like "2006-01-02", "2006-01-02 15:04:05" or "15:04:05"
+// instead of their display formats, so that they are loaded as BigQuery DATE, DATETIME or TIME.
+func XLSXISODates() XLSXOption {
+	return func(c *xlsxConfig) {
+		c.isoDates = true
+	}
+}
+
+// XLSXParser provides a stream parser to parse Office Open XML workbooks (.xlsx).
+// Cells are parsed as formatted in the workbook, and blank rows are skipped.
+// The iterator implements io.Closer to remove temporary files of the workbook.
+func XLSXParser(opts ...XLSXOption) StreamParser {
+	cfg := &xlsxConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+
+	return func(_ context.Context, r io.Reader) (RowIterator, error) {
+		rng, err := parseXLSXRange(cfg.cellRange)
+		if err != nil {
+			return nil, err
+		}
+
+		// Styles of cells are read from the worksheet in the archive alongside rows.
+		var data []byte
+		if cfg.isoDates {
+			if data, err = io.ReadAll(r); err != nil {
+				return nil, xerrors.Errorf("failed to read xlsx file: %w", err)
+			}
+			r = bytes.NewReader(data)
+		}
+
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to open xlsx file: %w", err)
+		}
+
+		it, err := newXLSXIterator(f, cfg, rng, data)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		if cfg.headerRow == 0 {
+			return it, nil
+		}
+
+		header, err := it.readHeader()
+		if err != nil {
+			it.Close()
+			return nil, err
+		}
+
+		return &headerXLSXIterator{xlsxIterator: it, header: header}, nil
+	}
+}
+
+// xlsxRange is a cell range in 1-based coordinates. Zero means unbounded.
+type xlsxRange struct {
+	fromCol, fromRow, toCol, toRow int
+}
+
+func parseXLSXRange(ref string) (xlsxRange, error) {
+	if ref == "" {
+		return xlsxRange{}, nil
+	}
+
+	cells := strings.Split(ref, ":")
+	if len(cells) != 2 {
+		return xlsxRange{}, xerrors.Errorf("invalid cell range: %s", ref)
+	}
+
+	fromCol, fromRow, err := excelize.CellNameToCoordinates(cells[0])
+	if err != nil {
+		return xlsxRange{}, xerrors.Errorf("invalid cell range %s: %w", ref, err)
+	}
+
+	toCol, toRow, err := excelize.CellNameToCoordinates(cells[1])
+	if err != nil {
+		return xlsxRange{}, xerrors.Errorf("invalid cell range %s: %w", ref, err)
+	}
+
+	if fromCol > toCol || fromRow > toRow {
+		return xlsxRange{}, xerrors.Errorf("invalid cell range: %s", ref)
+	}
+
+	return xlsxRange{fromCol: fromCol, fromRow: fromRow, toCol: toCol, toRow: toRow}, nil
+}
+
+type xlsxIterator struct {
+	file     *excelize.File
+	rows     *excelize.Rows
+	sheet    string
+	cfg      *xlsxConfig
+	rng      xlsxRange
+	row      int
+	date1904 bool
+	closed   bool
+
+	// cells reads raw values and styles of cells with XLSXISODates.
+	cells *xlsxCellReader
+
+	// dateStyles caches whether each style formats cells as dates.
+	dateStyles map[int]bool
+}
+
+func newXLSXIterator(f *excelize.File, cfg *xlsxConfig, rng xlsxRange, data []byte) (*xlsxIterator, error) {
+	sheet := cfg.sheetName
+	if sheet == "" {
+		sheets := f.GetSheetList()
+		if cfg.sheetIndex < 0 || cfg.sheetIndex >= len(sheets) {
+			return nil, xerrors.Errorf("%w: index %d", errXLSXNoSheet, cfg.sheetIndex)
+		}
+		sheet = sheets[cfg.sheetIndex]
+	}
+
+	if idx, err := f.GetSheetIndex(sheet); err != nil || idx < 0 {
+		return nil, xerrors.Errorf("%w: %s", errXLSXNoSheet, sheet)
+	}
+
+	props, err := f.GetWorkbookProps()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read workbook properties: %w", err)
+	}
+
+	var cells *xlsxCellReader
+	if data != nil {
+		if cells, err = newXLSXCellReader(data, sheet); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := f.Rows(sheet)
+	if err != nil {
+		if cells != nil {
+			cells.Close()
+		}
+		return nil, xerrors.Errorf("failed to read sheet %s: %w", sheet, err)
+	}
+
+	return &xlsxIterator{
+		file:       f,
+		rows:       rows,
+		sheet:      sheet,
+		cfg:        cfg,
+		rng:        rng,
+		date1904:   props.Date1904 != nil && *props.Date1904,
+		cells:      cells,
+		dateStyles: map[int]bool{},
+	}, nil
+}
+
+func (it *xlsxIterator) Next() ([]string, error) {
+	for {
+		record, err := it.read()
+		if err != nil {
+			return nil, err
+		}
+
+		if it.cfg.filter == nil || it.cfg.filter(record) {
+			return record, nil
+		}
+	}
+}
+
+// readHeader reads records until the header row.
+func (it *xlsxIterator) readHeader() ([]string, error) {
+	for {
+		record, err := it.read()
+		if errors.Is(err, io.EOF) {
+			return nil, errNoHeaderRow
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("failed to read header: %w", err)
+		}
+
+		if it.row == it.cfg.headerRow {
+			return record, nil
+		}
+		if it.row > it.cfg.headerRow {
+			return nil, errNoHeaderRow
+		}
+	}
+}
+
+// read returns the next non-blank record in the range.
+func (it *xlsxIterator) read() ([]string, error) {
+	for it.rows.Next() {
+		// Rows iterates every row including missing ones in the sheet.
+		it.row++
+
+		cols, err := it.rows.Columns()
+		if err != nil {
+			return nil, xerrors.Errorf("failed to read row %d: %w", it.row, err)
+		}
+
+		if it.rng.toRow > 0 && it.row > it.rng.toRow {
+			break
+		}
+		if it.row < it.rng.fromRow {
+			continue
+		}
+
+		record, err := it.record(cols)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isBlank(record) {
+			return record, nil
+		}
+	}
+
+	if err := it.rows.Error(); err != nil {
+		it.Close()
+		return nil, xerrors.Errorf("failed to read sheet %s: %w", it.sheet, err)
+	}
+
+	it.Close()
+
+	return nil, io.EOF
+}
+
+// record slices cells in the range and formats date cells.
+func (it *xlsxIterator) record(cols []string) ([]string, error) {
+	from := 1
+	if it.rng.fromCol > 0 {
+		from = it.rng.fromCol
+		width := it.rng.toCol - it.rng.fromCol + 1
+
+		record := make([]string, width)
+		if from <= len(cols) {
+			copy(record, cols[from-1:])
+		}
+		cols = record
+	}
+
+	if !it.cfg.isoDates {
+		return cols, nil
+	}
+
+	cells, err := it.cells.row(it.row)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range cols {
+		c, ok := cells[from+i]
+		if v == "" || !ok {
+			continue
+		}
+
+		iso, ok, err := it.isoDate(c)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			cols[i] = iso
+		}
+	}
+
+	return cols, nil
+}
+
+// isoDate formats the cell as an ISO 8601 string if it's formatted as a date.
+func (it *xlsxIterator) isoDate(c xlsxCell) (string, bool, error) {
+	isDate, ok := it.dateStyles[c.Style]
+	if !ok {
+		s, err := it.file.GetStyle(c.Style)
+		if err != nil {
+			return "", false, xerrors.Errorf("failed to get style of %s: %w", c.Ref, err)
+		}
+		isDate = isDateNumFmt(s)
+		it.dateStyles[c.Style] = isDate
+	}
+
+	// Texts in date-formatted cells are kept as is.
+	if !isDate || (c.Type != "" && c.Type != "n") {
+		return "", false, nil
+	}
+
+	serial, err := strconv.ParseFloat(c.Value, 64)
+	if err != nil {
+		return "", false, nil
+	}
+
+	t, err := excelize.ExcelDateToTime(serial, it.date1904)
+	if err != nil {
+		return "", false, xerrors.Errorf("invalid date in %s: %w", c.Ref, err)
+	}
+
+	switch {
+	case serial < 1:
+		return t.Format("15:04:05"), true, nil
+	case serial == math.Trunc(serial):
+		return t.Format("2006-01-02"), true, nil
+	default:
+		return t.Format("2006-01-02 15:04:05"), true, nil
+	}
+}
+
+// Close releases the workbook and its temporary files. It's safe to call Close more than once.
+func (it *xlsxIterator) Close() error {
+	if it.closed {
+		return nil
+	}
+	it.closed = true
+
+	it.rows.Close()
+	if it.cells != nil {
+		it.cells.Close()
+	}
+
+	return it.file.Close()
+}
+
+type headerXLSXIterator struct {
+	*xlsxIterator
+	header []string
+}
+
+func (it *headerXLSXIterator) Header() []string {
+	return it.header
+}
+
+// xlsxCell is a cell in the worksheet XML.
+type xlsxCell struct {
+	Ref   string `xml:"r,attr"`
+	Style int    `xml:"s,attr"`
+	Type  string `xml:"t,attr"`
+	Value string `xml:"v"`
+}
+
+type xlsxRow struct {
+	Num   int        `xml:"r,attr"`
+	Cells []xlsxCell `xml:"c"`
+}
+
+// xlsxCellReader reads rows of the worksheet XML in the archive one by one
+// to get raw values and styles of cells, which excelize.Rows doesn't provide.
+type xlsxCellReader struct {
+	rc      io.ReadCloser
+	decoder *xml.Decoder
+	num     int
+	cells   map[int]xlsxCell
+}
+
+func newXLSXCellReader(data []byte, sheet string) (*xlsxCellReader, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, xerrors.Errorf("failed to open xlsx file: %w", err)
+	}
+
+	name, err := xlsxSheetPath(zr, sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := zr.Open(name)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to open sheet %s: %w", sheet, err)
+	}
+
+	return &xlsxCellReader{rc: rc, decoder: xml.NewDecoder(rc)}, nil
+}
+
+// row returns cells of the n-th row (1-based) by their column numbers.
+// Rows must be read in ascending order.
+func (r *xlsxCellReader) row(n int) (map[int]xlsxCell, error) {
+	for r.num < n && r.decoder != nil {
+		tok, err := r.decoder.Token()
+		if errors.Is(err, io.EOF) {
+			r.decoder = nil
+			break
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("failed to read row %d: %w", r.num+1, err)
+		}
+
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "row" {
+			continue
+		}
+
+		var row xlsxRow
+		if err := r.decoder.DecodeElement(&row, &se); err != nil {
+			return nil, xerrors.Errorf("failed to read row %d: %w", r.num+1, err)
+		}
+
+		if row.Num == 0 {
+			row.Num = r.num + 1
+		}
+		r.num = row.Num
+		r.cells = make(map[int]xlsxCell, len(row.Cells))
+
+		col := 0
+		for _, c := range row.Cells {
+			col++
+			if c.Ref != "" {
+				if col, _, err = excelize.CellNameToCoordinates(c.Ref); err != nil {
+					return nil, xerrors.Errorf("invalid cell %s: %w", c.Ref, err)
+				}
+			}
+			r.cells[col] = c
+		}
+	}
+
+	if r.num != n {
+		return nil, nil
+	}
+
+	return r.cells, nil
+}
+
+func (r *xlsxCellReader) Close() error {
+	return r.rc.Close()
+}
+
+// xlsxSheetPath returns the path of the sheet in the archive.
+func xlsxSheetPath(zr *zip.Reader, sheet string) (string, error) {
+	var wb struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := readZipXML(zr, "xl/workbook.xml", &wb); err != nil {
+		return "", err
+	}
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := readZipXML(zr, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+
+	for _, s := range wb.Sheets {
+		if s.Name != sheet {
+			continue
+		}
+
+		for _, rel := range rels.Relationships {
+			if rel.ID != s.ID {
+				continue
+			}
+
+			if strings.HasPrefix(rel.Target, "/") {
+				return rel.Target[1:], nil
+			}
+
+			return path.Join("xl", rel.Target), nil
+		}
+	}
+
+	return "", xerrors.Errorf("%w: %s", errXLSXNoSheet, sheet)
+}
+
+func readZipXML(zr *zip.Reader, name string, v interface{}) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return xerrors.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	if err := xml.NewDecoder(f).Decode(v); err != nil {
+		return xerrors.Errorf("failed to decode %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// isDateNumFmt reports whether the style formats numbers as dates or times.
+func isDateNumFmt(s *excelize.Style) bool {
+	if s.CustomNumFmt != nil {
+		return isDateFormatCode(*s.CustomNumFmt)
+	}
+
+	id := s.NumFmt
+
+	// Built-in formats of dates and times including ones of CJK languages.
+	return (14 <= id && id <= 22) || (27 <= id && id <= 36) || (45 <= id && id <= 47) || (50 <= id && id <= 58)
+}
+
+// isDateFormatCode reports whether the format code has date or time tokens
+// out of quoted literals, escaped characters and brackets except elapsed times like [h].
+func isDateFormatCode(code string) bool {
+	quoted := false
+	escaped := false
+	var bracket *strings.Builder
+
+	for _, c := range strings.ToLower(code) {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted:
+			quoted = c != '"'
+		case bracket != nil:
+			if c != ']' {
+				bracket.WriteRune(c)
+				continue
+			}
+			if b := bracket.String(); b != "" && strings.Trim(b, "hms") == "" {
+				return true
+			}
+			bracket = nil
+		case c == '"':
+			quoted = true
+		case c == '\\' || c == '_' || c == '*':
+			escaped = true
+		case c == '[':
+			bracket = &strings.Builder{}
+		case strings.ContainsRune("ymdhs", c):
+			return true
+		}
+	}
+
+	return false
+}
+
+func isBlank(record []string) bool {
+	for _, v := range record {
+		if v != "" {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,223 @@
+package bqloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// newTestWorkbook builds a workbook with a title, a header and statement rows in the second sheet.
+func newTestWorkbook(t *testing.T) []byte {
+	t.Helper()
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if _, err := f.NewSheet("Statement"); err != nil {
+		t.Fatal(err)
+	}
+
+	dateStyle, err := f.NewStyle(&excelize.Style{NumFmt: 14})
+	if err != nil {
+		t.Fatal(err)
+	}
+	customDateStyle := "yyyy\"年\"m\"月\"d\"日\""
+	jaDateStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &customDateStyle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	amountFmt := "#,##0;[Red]-#,##0"
+	amountStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &amountFmt})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := map[string][]interface{}{
+		"A1": {"Statement of July"},
+		"A3": {"date", "description", "amount", "booked_at"},
+		"A4": {time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC), "foo", 1000, time.Date(2022, 7, 2, 12, 30, 0, 0, time.UTC)},
+		"A5": {time.Date(2022, 7, 3, 0, 0, 0, 0, time.UTC), "bar", -200},
+		"A7": {time.Date(2022, 7, 5, 0, 0, 0, 0, time.UTC), "total", 800},
+	}
+	for cell, values := range rows {
+		values := values
+		if err := f.SetSheetRow("Statement", cell, &values); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, s := range []struct {
+		from, to string
+		style    int
+	}{
+		{"A4", "A7", dateStyle},
+		{"D4", "D4", jaDateStyle},
+		{"C4", "C7", amountStyle},
+	} {
+		if err := f.SetCellStyle("Statement", s.from, s.to, s.style); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestXLSXParser(t *testing.T) {
+	t.Parallel()
+
+	wb := newTestWorkbook(t)
+	notTotal := func(r []string) bool { return r[1] != "total" }
+
+	cases := map[string]struct {
+		opts     []XLSXOption
+		header   []string
+		expected [][]string
+		err      error
+	}{
+		"default sheet": {
+			opts:     nil,
+			expected: [][]string{},
+		},
+		"sheet index": {
+			opts: []XLSXOption{XLSXSheetIndex(1)},
+			expected: [][]string{
+				{"Statement of July"},
+				{"date", "description", "amount", "booked_at"},
+				{"07-01-22", "foo", "1,000", "2022年7月2日"},
+				{"07-03-22", "bar", "-200"},
+				{"07-05-22", "total", "800"},
+			},
+		},
+		"sheet name with range": {
+			opts: []XLSXOption{XLSXSheetName("Statement"), XLSXRange("A4:C5")},
+			expected: [][]string{
+				{"07-01-22", "foo", "1,000"},
+				{"07-03-22", "bar", "-200"},
+			},
+		},
+		"header, filter and ISO dates": {
+			opts: []XLSXOption{
+				XLSXSheetName("Statement"), XLSXHeaderRow(3), XLSXRowFilter(notTotal), XLSXISODates(),
+			},
+			header: []string{"date", "description", "amount", "booked_at"},
+			expected: [][]string{
+				{"2022-07-01", "foo", "1,000", "2022-07-02 12:30:00"},
+				{"2022-07-03", "bar", "-200"},
+			},
+		},
+		"range and ISO dates": {
+			opts: []XLSXOption{XLSXSheetName("Statement"), XLSXRange("C4:D5"), XLSXISODates()},
+			expected: [][]string{
+				{"1,000", "2022-07-02 12:30:00"},
+				{"-200", ""},
+			},
+		},
+		"range wider than cells": {
+			opts:     []XLSXOption{XLSXSheetName("Statement"), XLSXRange("B5:E5")},
+			expected: [][]string{{"bar", "-200", "", ""}},
+		},
+		"unknown sheet name": {
+			opts: []XLSXOption{XLSXSheetName("Unknown")},
+			err:  errXLSXNoSheet,
+		},
+		"sheet index out of range": {
+			opts: []XLSXOption{XLSXSheetIndex(2)},
+			err:  errXLSXNoSheet,
+		},
+		"header row beyond rows": {
+			opts: []XLSXOption{XLSXSheetIndex(1), XLSXHeaderRow(10)},
+			err:  errNoHeaderRow,
+		},
+		"header row out of range": {
+			opts: []XLSXOption{XLSXSheetIndex(1), XLSXRange("A4:D7"), XLSXHeaderRow(3)},
+			err:  errNoHeaderRow,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			it, err := XLSXParser(c.opts...)(context.Background(), bytes.NewReader(wb))
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Errorf("expected %v, but %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if c.header != nil {
+				hi, ok := it.(HeaderIterator)
+				if !ok {
+					t.Fatalf("expected HeaderIterator")
+				}
+				if strings.Join(hi.Header(), ",") != strings.Join(c.header, ",") {
+					t.Errorf("header should be %v, but %v", c.header, hi.Header())
+				}
+			}
+
+			actual := [][]string{}
+			for {
+				r, err := it.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				actual = append(actual, r)
+			}
+
+			assertRecords(t, c.expected, actual)
+		})
+	}
+}
+
+func TestXLSXParser_InvalidRange(t *testing.T) {
+	t.Parallel()
+
+	for _, ref := range []string{"A1", "A1:", "C1:A3", "A3:C1"} {
+		if _, err := parseXLSXRange(ref); err == nil {
+			t.Errorf("expected error for %q", ref)
+		}
+	}
+}
+
+func TestIsDateFormatCode(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]bool{
+		"yyyy/mm/dd":            true,
+		"[$-411]ge.m.d":         true,
+		"h:mm AM/PM":            true,
+		"[h]:mm":                true,
+		"#,##0;[Red]-#,##0":     false,
+		"[Magenta]0.00":         false,
+		"0.00E+00":              false,
+		"\"days\" 0":            false,
+		"\\d0":                  false,
+		"General":               false,
+		"yyyy\"年\"m\"月\"d\"日\"": true,
+	}
+
+	for code, expected := range cases {
+		if actual := isDateFormatCode(code); actual != expected {
+			t.Errorf("isDateFormatCode(%q) should be %v, but %v", code, expected, actual)
+		}
+	}
+}